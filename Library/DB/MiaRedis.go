@@ -12,9 +12,14 @@ import (
 	gconv "github.com/og/x/conv"
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// redisLog is the MiaLog module of the redis driver and job queue.
+var redisLog = MiaLog.Named("redis")
+
 // Config the redis configuration used inside sessions
 type RedisConfig struct {
 	// Network protocol. Defaults to "tcp".
@@ -42,30 +47,18 @@ type RadixDriver struct {
 	Config           RedisConfig
 	pool             *radix.Pool
 	IsCheckReconnect bool
-}
-
-// Connect connects to the redis, called only once
-func (r *RadixDriver) ReConnect(c RedisConfig) error {
-	if c.Timeout < 0 {
-		c.Timeout = time.Duration(30) * time.Second
-	}
-
-	if c.Network == "" {
-		c.Network = "tcp"
-	}
 
-	if c.Addr == "" {
-		c.Addr = "127.0.0.1:6379"
-	}
+	poolMutex sync.RWMutex // guards pool and Config.Database, swapped by ReConnect and SelectDb
 
-	if c.MaxActive == 0 {
-		c.MaxActive = 10
-	}
+	dbMutex sync.Mutex
+	dbs     map[string]*RadixDriver // database index -> driver bound to it
 
-	if c.Delim == "" {
-		c.Delim = "-"
-	}
+	instrument atomic.Value // *redisInstrument, see SetMetrics and SetSlowLog
+}
 
+// newPool dials a pool whose every connection AUTHs and SELECTs per c,
+// so the selected database never leaks between pool users.
+func (r *RadixDriver) newPool(c RedisConfig) (*radix.Pool, error) {
 	customConnFunc := func(network, addr string) (radix.Conn, error) {
 		var options []radix.DialOpt
 
@@ -88,44 +81,125 @@ func (r *RadixDriver) ReConnect(c RedisConfig) error {
 		return radix.Dial(network, addr, options...)
 	}
 
-	pool, err := radix.NewPool(c.Network, c.Addr, c.MaxActive, radix.PoolConnFunc(customConnFunc))
-	MiaLog.CInfo(c.Addr, c.Network)
+	return radix.NewPool(c.Network, c.Addr, c.MaxActive, radix.PoolConnFunc(customConnFunc), radix.PoolWithTrace(r.poolTrace()))
+}
+
+// Connect connects to the redis, called only once
+func (r *RadixDriver) ReConnect(c RedisConfig) error {
+	if c.Timeout < 0 {
+		c.Timeout = time.Duration(30) * time.Second
+	}
+
+	if c.Network == "" {
+		c.Network = "tcp"
+	}
+
+	if c.Addr == "" {
+		c.Addr = "127.0.0.1:6379"
+	}
+
+	if c.MaxActive == 0 {
+		c.MaxActive = 10
+	}
+
+	if c.Delim == "" {
+		c.Delim = "-"
+	}
+
+	pool, err := r.newPool(c)
+	redisLog.Info(c.Addr, c.Network)
 	if err != nil {
-		MiaLog.CInfo(err.Error())
+		redisLog.Info(err.Error())
 		r.IsCheckReconnect = true
 		return err
 	}
 
+	r.poolMutex.Lock()
 	r.Connected = true
 	r.pool = pool
 	r.Config = c
+	r.poolMutex.Unlock()
 	return nil
 }
 
+// getPool returns the current pool, which SelectDb may replace at any time.
+func (r *RadixDriver) getPool() *radix.Pool {
+	r.poolMutex.RLock()
+	defer r.poolMutex.RUnlock()
+	return r.pool
+}
+
 //心跳包
 // PingPong sends a ping and receives a pong, if no pong received then returns false and filled error
 func (r *RadixDriver) PingPong() (bool, error) {
 	var msg string
-	err := r.pool.Do(radix.Cmd(&msg, "PING"))
+	err := r.do(radix.Cmd(&msg, "PING"))
 	if err != nil {
 		return false, err
 	}
 	return (msg == "PONG"), nil
 }
 
-// CloseConnection closes the redis connection.
+// CloseConnection closes the redis connection,
+// including the pools of every driver returned by `Database`.
 func (r *RadixDriver) CloseConnection() error {
-	if r.pool != nil {
-		return r.pool.Close()
+	r.dbMutex.Lock()
+	for idx, db := range r.dbs {
+		if err := db.CloseConnection(); err != nil {
+			redisLog.Error("redis: close database ", idx, " error:", err)
+		}
+	}
+	r.dbs = nil
+	r.dbMutex.Unlock()
+
+	if pool := r.getPool(); pool != nil {
+		return pool.Close()
 	}
 	return errors.New("redis: already closed")
 }
 
+// Database returns a driver bound to the logical database idx.
+// It shares the config (and prefix) of r but owns a separate pool whose
+// connections are dialed with SELECT idx, so it is safe for concurrent use.
+// Drivers are cached per index and closed together with r.
+func (r *RadixDriver) Database(idx string) (*RadixDriver, error) {
+	r.poolMutex.RLock()
+	current := r.Config.Database
+	r.poolMutex.RUnlock()
+	if idx == current {
+		return r, nil
+	}
+	if _, err := strconv.Atoi(idx); err != nil {
+		return nil, fmt.Errorf("redis: invalid database index %q: %w", idx, err)
+	}
+
+	r.dbMutex.Lock()
+	defer r.dbMutex.Unlock()
+	if db, ok := r.dbs[idx]; ok {
+		return db, nil
+	}
+
+	r.poolMutex.RLock()
+	c := r.Config
+	r.poolMutex.RUnlock()
+	c.Database = idx
+	db := new(RadixDriver)
+	db.instrument.Store(r.loadInstrument())
+	if err := db.ReConnect(c); err != nil {
+		return nil, err
+	}
+	if r.dbs == nil {
+		r.dbs = make(map[string]*RadixDriver)
+	}
+	r.dbs[idx] = db
+	return db, nil
+}
+
 // Get returns value, err by its key
 // returns nil and a filled error if something bad happened.
 func (r *RadixDriver) Get(key string) (redisVal string, err error) {
 	mn := radix.MaybeNil{Rcv: &redisVal}
-	err = r.pool.Do(radix.Cmd(&mn, "GET", r.Config.Prefix+key))
+	err = r.do(radix.Cmd(&mn, "GET", r.Config.Prefix+key))
 	if MiaError.CheckError(err) {
         return "",err
     }
@@ -136,7 +210,7 @@ func (r *RadixDriver) Get(key string) (redisVal string, err error) {
 }
 func (r *RadixDriver) GetByte(key string) (redisVal []byte, err error) {
 	mn := radix.MaybeNil{Rcv: &redisVal}
-	err = r.pool.Do(radix.Cmd(&mn, "GET", r.Config.Prefix+key))
+	err = r.do(radix.Cmd(&mn, "GET", r.Config.Prefix+key))
 	if MiaError.CheckError(err) {
 		return nil,err
 	}
@@ -152,28 +226,28 @@ func (r *RadixDriver) Set(key string, value interface{}, secondsLifetime int64)
 	} else {
 		cmd = radix.FlatCmd(nil, "SET", r.Config.Prefix+key, value) // MSET same performance...
 	}
-	return r.pool.Do(cmd)
+	return r.do(cmd)
 }
 func (r *RadixDriver) Incr(key string) error {
-	err := r.pool.Do(radix.Cmd(nil, "incr", r.Config.Prefix+key))
+	err := r.do(radix.Cmd(nil, "incr", r.Config.Prefix+key))
 	return err
 }
 func (r *RadixDriver) Delete(key string) error {
-	err := r.pool.Do(radix.Cmd(nil, "DEL", r.Config.Prefix+key))
+	err := r.do(radix.Cmd(nil, "DEL", r.Config.Prefix+key))
 	return err
 }
 func (r *RadixDriver) Exec(action radix.CmdAction)  (error){
-    err := r.pool.Do(action)
+    err := r.do(action)
     MiaError.CheckError(err)
     return err
 }
 
 func (r *RadixDriver) DeletePrefix(prefix string) {
 	var keyString []string
-	err := r.pool.Do(radix.FlatCmd(&keyString, "keys", prefix+"*"))
+	err := r.do(radix.FlatCmd(&keyString, "keys", prefix+"*"))
 	if !MiaError.CheckError(err) {
 		for _, v := range keyString {
-			err = r.pool.Do(radix.FlatCmd(nil, "Del", v))
+			err = r.do(radix.FlatCmd(nil, "Del", v))
 			MiaError.CheckError(err)
 		}
 	}
@@ -181,7 +255,7 @@ func (r *RadixDriver) DeletePrefix(prefix string) {
 
 func (r *RadixDriver) TTL(key string) (seconds int64, hasExpiration bool, found bool) {
 	var redisVal interface{}
-	err := r.pool.Do(radix.Cmd(&redisVal, "TTL", r.Config.Prefix+key))
+	err := r.do(radix.Cmd(&redisVal, "TTL", r.Config.Prefix+key))
 	if err != nil {
 		return -2, false, false
 	}
@@ -192,7 +266,7 @@ func (r *RadixDriver) TTL(key string) (seconds int64, hasExpiration bool, found
 }
 func (r *RadixDriver) updateTTLConn(key string, newSecondsLifeTime int64) error {
 	var reply int
-	err := r.pool.Do(radix.FlatCmd(&reply, "EXPIRE", r.Config.Prefix+key, newSecondsLifeTime))
+	err := r.do(radix.FlatCmd(&reply, "EXPIRE", r.Config.Prefix+key, newSecondsLifeTime))
 	if err != nil {
 		return err
 	}
@@ -228,27 +302,52 @@ func (s *scanResult) UnmarshalRESP(br *bufio.Reader) error {
 
 	return (resp2.Any{I: &s.keys}).UnmarshalRESP(br)
 }
-func(r *RadixDriver)SelectDb(idx string) (error) {
-	p:=radix.Cmd(nil, "SELECT", idx)
-	return  r.pool.Do(p)
+// SelectDb rebinds r to the logical database idx by replacing its pool,
+// every new pooled connection is dialed with SELECT idx. Commands already
+// running finish on the old pool, which is closed afterwards.
+//
+// Deprecated: every user of r switches database, use `Database` which
+// returns a separate driver bound to idx.
+func (r *RadixDriver) SelectDb(idx string) error {
+	if _, err := strconv.Atoi(idx); err != nil {
+		return fmt.Errorf("redis: invalid database index %q: %w", idx, err)
+	}
+	r.poolMutex.RLock()
+	c := r.Config
+	r.poolMutex.RUnlock()
+	c.Database = idx
+	pool, err := r.newPool(c)
+	if err != nil {
+		return err
+	}
+
+	r.poolMutex.Lock()
+	old := r.pool
+	r.pool = pool
+	r.Config.Database = idx
+	r.poolMutex.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
 }
-func (r *RadixDriver)GetMembersArray(idx string,key string) ([]string,error) {
+
+// GetMembersArray returns the members of the set key stored in database idx.
+func (r *RadixDriver) GetMembersArray(idx string, key string) ([]string, error) {
+	db, err := r.Database(idx)
+	if err != nil {
+		return nil, err
+	}
 	sids := []string{}
-	p := radix.Pipeline(
-		radix.Cmd(nil, "SELECT", idx),
-		radix.Cmd(&sids, "SMEMBERS", key),
-		radix.Cmd(nil, "SELECT", r.Config.Database),
-	)
-	if err := r.pool.Do(p); err != nil {
+	if err := db.do(radix.Cmd(&sids, "SMEMBERS", key)); err != nil {
 		return nil, err
-	}else{
-		return sids,err	
 	}
+	return sids, nil
 }
 //查询出所有相似的key
 func (r *RadixDriver) GetPageKeys(cursor, prefix string,pageCount string) ([]string, int, error) {
 	var res scanResult
-	err := r.pool.Do(radix.Cmd(&res, "SCAN", cursor, "MATCH", r.Config.Prefix+prefix+"*", "COUNT", pageCount))
+	err := r.do(radix.Cmd(&res, "SCAN", cursor, "MATCH", r.Config.Prefix+prefix+"*", "COUNT", pageCount))
 	if err != nil {
 		return nil,0, err
 	}
@@ -269,7 +368,7 @@ func (r *RadixDriver) GetPageKeys(cursor, prefix string,pageCount string) ([]str
 //查询出所有相似的key
 func (r *RadixDriver) GetKeys(cursor, prefix string) ([]string, error) {
 	var res scanResult
-	err := r.pool.Do(radix.Cmd(&res, "SCAN", cursor, "MATCH", r.Config.Prefix+prefix+"*", "COUNT", "1000"))
+	err := r.do(radix.Cmd(&res, "SCAN", cursor, "MATCH", r.Config.Prefix+prefix+"*", "COUNT", "1000"))
 	if err != nil {
 		return nil, err
 	}
@@ -287,7 +386,7 @@ func (r *RadixDriver) GetKeys(cursor, prefix string) ([]string, error) {
 	return keys, nil
 }
 func (r *RadixDriver)GetPool() *radix.Pool{
-	return r.pool;
+	return r.getPool();
 }
 // UpdateTTLMany like `UpdateTTL` but for all keys starting with that "prefix",
 // it is a bit faster operation if you need to update all sessions keys (although it can be even faster if we used hash but this will limit other features),
@@ -316,7 +415,7 @@ func (r *RadixDriver) UpdateTTL(key string, newSecondsLifeTime int64) error {
 
 func (self *RadixDriver) Exists(key string) (exists bool) {
 	data := radix.MaybeNil{Rcv: &exists}
-	err := self.pool.Do(radix.Cmd(&data, EXISTS, key))
+	err := self.do(radix.Cmd(&data, EXISTS, key))
 	Check(err)
 	return
 }
@@ -327,7 +426,7 @@ func (r *RadixDriver) IsSetTableExist(key string, existValue string) (IsExist bo
 		return false
 	}
 	data := radix.MaybeNil{Rcv: &IsExist}
-	err := r.pool.Do(radix.Cmd(&data, "sismember", key, existValue))
+	err := r.do(radix.Cmd(&data, "sismember", key, existValue))
 	MiaError.CheckError(err)
 	return
 }
@@ -346,60 +445,15 @@ func (r *RadixDriver) SaveSliceToRedisSet(key string, info interface{}) (err err
 
 		}
 	}
-	return r.pool.Do(radix.Cmd(nil, "sadd", list...))
+	return r.do(radix.Cmd(nil, "sadd", list...))
 }
 
-//SaveToRedis 将一个结构保存到redis中
+//SaveToRedis 将一个结构保存到redis中, 所有字段在一个pipeline中写入
 func (r *RadixDriver) SaveToRedis(key string, info interface{}) {
-	tableName := key
-	dataStruct := reflect.Indirect(reflect.ValueOf(info))
-	dataStructType := dataStruct.Type()
-	for i := 0; i < dataStructType.NumField(); i++ {
-		fieldType := dataStructType.Field(i)
-		fieldValue := dataStruct.Field(i)
-
-		switch fieldType.Type.Kind() {
-		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-			str := strconv.FormatInt(fieldValue.Int(), 10)
-			err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, str))
-			MiaError.CheckError(err)
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			str := strconv.FormatUint(fieldValue.Uint(), 10)
-			err := r.pool.Do(radix.Cmd(nil, "Hset", tableName, fieldType.Name, str))
-			MiaError.CheckError(err)
-		case reflect.Float32, reflect.Float64:
-			str := strconv.FormatFloat(fieldValue.Float(), 'f', -1, 64)
-			err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, str))
-			MiaError.CheckError(err)
-
-		case reflect.String:
-			//client.HSet(tableName, fieldType.Name, fieldValue.String())
-			err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, fieldValue.String()))
-			MiaError.CheckError(err)
-		//时间类型
-		case reflect.Struct:
-			str := strconv.FormatInt(fieldValue.Interface().(time.Time).Unix(), 10)
-			// client.HSet(tableName, fieldType.Name, str)
-			err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, str))
-			MiaError.CheckError(err)
-		case reflect.Bool:
-			if fieldValue.Bool() {
-				//  client.HSet(tableName, fieldType.Name, "1")
-				err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, gconv.IntString(1)))
-				MiaError.CheckError(err)
-			} else {
-				// client.HSet(tableName, fieldType.Name, "0")
-				err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, gconv.IntString(0)))
-				MiaError.CheckError(err)
-			}
-		case reflect.Slice:
-			if fieldType.Type.Elem().Kind() == reflect.Uint8 {
-				// client.HSet(tableName, fieldType.Name, string(fieldValue.Interface().([]byte)))
-				err := r.pool.Do(radix.Cmd(nil, "HSet", tableName, fieldType.Name, string(fieldValue.Interface().([]byte))))
-				MiaError.CheckError(err)
-			}
-		}
-	}
+	b := r.NewBatch()
+	b.HSetStruct(key, info)
+	_, err := b.Exec()
+	MiaError.CheckError(err)
 }
 
 //LoadFromRedis 从redis中读取一个结构
@@ -411,7 +465,7 @@ func (r *RadixDriver) LoadFromRedis(key string, info interface{}) (err error) {
 	dataStruct := reflect.Indirect(reflect.ValueOf(info))
 	dataStructType := dataStruct.Type()
 	var resultstringmap map[string]string
-	err = r.pool.Do(radix.Cmd(&resultstringmap, "HGETALL", key))
+	err = r.do(radix.Cmd(&resultstringmap, "HGETALL", key))
 	MiaError.CheckError(err)
 	for key, value := range resultstringmap {
 		for i := 0; i < dataStructType.NumField(); i++ {
@@ -452,9 +506,9 @@ func (r *RadixDriver) Hmset(key string, values interface{}, ttl string) (string,
 	}
 	var reply string
 	var expire int
-	err := r.pool.Do(radix.FlatCmd(&reply, "HMSET", key, values))
+	err := r.do(radix.FlatCmd(&reply, "HMSET", key, values))
 	if ttl != "" {
-		err = r.pool.Do(radix.Cmd(&expire, "EXPIRE", key, ttl))
+		err = r.do(radix.Cmd(&expire, "EXPIRE", key, ttl))
 	}
 	if err != nil {
 		fmt.Println(err)
@@ -476,7 +530,7 @@ func (r *RadixDriver) GetFieldFromRedis(key string, info interface{}, field stri
 		if fieldType.Name == field {
 			fieldValue := dataStruct.Field(i)
 			var v string
-			err = r.pool.Do(radix.Cmd(&r, "HGET", key, field))
+			err = r.do(radix.Cmd(&r, "HGET", key, field))
 			switch fieldType.Type.Kind() {
 			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
 				n, _ := strconv.Atoi(v)
@@ -519,34 +573,34 @@ func (r *RadixDriver) SetFieldFromRedis(key string, info interface{}, field stri
 			switch fieldType.Type.Kind() {
 			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
 				str := strconv.FormatInt(fieldValue.Int(), 10)
-				r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
+				r.do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
 			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 				str := strconv.FormatUint(fieldValue.Uint(), 10)
-				r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
+				r.do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
 			case reflect.Float32, reflect.Float64:
 				str := strconv.FormatFloat(fieldValue.Float(), 'f', -1, 64)
-				r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
+				r.do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
 			case reflect.String:
 				//synSender.Do(currentCtx,"hset",key,fieldType.Name, fieldValue.String())
-				r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, fieldValue.String()))
+				r.do(radix.Cmd(nil, "hset", key, fieldType.Name, fieldValue.String()))
 			//时间类型
 			case reflect.Struct:
 				str := strconv.FormatInt(fieldValue.Interface().(time.Time).Unix(), 10)
 				//client.HSet(tableName, fieldType.Name, str)
 				//  synSender.Do(currentCtx,"hset",key,fieldType.Name, str)
-				r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
+				r.do(radix.Cmd(nil, "hset", key, fieldType.Name, str))
 			case reflect.Bool:
 				if fieldValue.Bool() {
 					///client.HSet(tableName, fieldType.Name, "1")
-					r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, "1"))
+					r.do(radix.Cmd(nil, "hset", key, fieldType.Name, "1"))
 
 				} else {
-					r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, "0"))
+					r.do(radix.Cmd(nil, "hset", key, fieldType.Name, "0"))
 				}
 			case reflect.Slice:
 				if fieldType.Type.Elem().Kind() == reflect.Uint8 {
 					//client.HSet(tableName, fieldType.Name, string(fieldValue.Interface().([]byte)))
-					r.pool.Do(radix.Cmd(nil, "hset", key, fieldType.Name, string(fieldValue.Interface().([]byte))))
+					r.do(radix.Cmd(nil, "hset", key, fieldType.Name, string(fieldValue.Interface().([]byte))))
 				}
 			}
 			return nil
@@ -558,7 +612,7 @@ func (r *RadixDriver) SetFieldFromRedis(key string, info interface{}, field stri
 //ZAdd zadd
 func (r *RadixDriver)ZAdd(key string, score string, member string)(int ,error ){
 	intValue:=0
-	err:= r.pool.Do(radix.Cmd(&intValue, "ZADD", r.Config.Prefix+key, score, member))
+	err:= r.do(radix.Cmd(&intValue, "ZADD", r.Config.Prefix+key, score, member))
 	if MiaError.CheckError(err) {
 		return  intValue,err
 	}
@@ -568,7 +622,7 @@ func (r *RadixDriver)ZAdd(key string, score string, member string)(int ,error ){
 //ZRem zrem
 func (r *RadixDriver)ZRem(key string, member string) (int ,error ){
 	intValue:=0
-	err :=  r.pool.Do(radix.Cmd(&intValue, "ZREM", r.Config.Prefix+key, member))
+	err :=  r.do(radix.Cmd(&intValue, "ZREM", r.Config.Prefix+key, member))
 	if MiaError.CheckError(err) {
 		return  intValue,err
 	}
@@ -578,7 +632,7 @@ func (r *RadixDriver)ZRem(key string, member string) (int ,error ){
 //ZScore zscore
 func (r *RadixDriver)ZScore(key string, member string) (int,error) {  //查询指定 成员的成绩
 	var rresult int
-	err := r.pool.Do(radix.Cmd(&rresult, "ZSCORE", r.Config.Prefix+key, member))
+	err := r.do(radix.Cmd(&rresult, "ZSCORE", r.Config.Prefix+key, member))
 	if err != nil {
 		return 0,err
 	}
@@ -588,7 +642,7 @@ func (r *RadixDriver)ZScore(key string, member string) (int,error) {  //查询
 //ZRank 获取指定成员所在的有序集合里面的位置
 func  (r *RadixDriver)ZRank(key string, member string) int {
 	var rt int
-	err := r.pool.Do(radix.Cmd(&rt, "ZRANK", r.Config.Prefix+key, member))
+	err := r.do(radix.Cmd(&rt, "ZRANK", r.Config.Prefix+key, member))
 	if err != nil {
 		return 0
 	}
@@ -598,7 +652,7 @@ func  (r *RadixDriver)ZRank(key string, member string) int {
 //ZRevRank 返回有序集合中指定成员的排名，有序集成员按分数值递减(从大到小)排序
 func  (r *RadixDriver)ZRevRank(key string, member string) int {
 	var rt int
-	err := r.pool.Do(radix.Cmd(&rt, "ZREVRANK", r.Config.Prefix+key, member))
+	err := r.do(radix.Cmd(&rt, "ZREVRANK", r.Config.Prefix+key, member))
 	if err != nil {
 		return 0
 	}
@@ -608,7 +662,7 @@ func  (r *RadixDriver)ZRevRank(key string, member string) int {
 //ZCount zcount
 func  (r *RadixDriver)ZCount(key string) int {
 	var rt int
-	err := r.pool.Do(radix.Cmd(&rt, "ZCOUNT", r.Config.Prefix+key, "-inf", "+inf"))
+	err := r.do(radix.Cmd(&rt, "ZCOUNT", r.Config.Prefix+key, "-inf", "+inf"))
 	if err != nil {
 		return 0
 	}
@@ -618,7 +672,7 @@ func  (r *RadixDriver)ZCount(key string) int {
 //ZRevRange zrevrange
 func  (r *RadixDriver)ZRevRange(key string, startScore, endScore string) []string {
 	var rt []string
-	err := r.pool.Do(radix.Cmd(&rt, "zrevrange", r.Config.Prefix+key, startScore, endScore))
+	err := r.do(radix.Cmd(&rt, "zrevrange", r.Config.Prefix+key, startScore, endScore))
 	if err != nil {
 		rt = make([]string, 0)
 	}
@@ -627,7 +681,7 @@ func  (r *RadixDriver)ZRevRange(key string, startScore, endScore string) []strin
 //ZRevRange zrevrange
 func  (r *RadixDriver)ZRange(key string, startScore, endScore string) []string {
 	var rt []string
-	err := r.pool.Do(radix.Cmd(&rt, "ZRANGE", r.Config.Prefix+key, startScore, endScore))
+	err := r.do(radix.Cmd(&rt, "ZRANGE", r.Config.Prefix+key, startScore, endScore))
 	if err != nil {
 		rt = make([]string, 0)
 	}
@@ -636,44 +690,44 @@ func  (r *RadixDriver)ZRange(key string, startScore, endScore string) []string {
 //ZRevRangeByScore zrevrangebyscore
 func (r *RadixDriver) ZRevRangeByScore(key string, startScore, endScore, beingindex, limit string) []string {
 	var rt []string
-	err := r.pool.Do(radix.Cmd(&rt, "ZREVRANGEBYSCORE", r.Config.Prefix+key, "("+startScore, endScore, "LIMIT", beingindex, limit))
+	err := r.do(radix.Cmd(&rt, "ZREVRANGEBYSCORE", r.Config.Prefix+key, "("+startScore, endScore, "LIMIT", beingindex, limit))
 	if err != nil {
 		rt = make([]string, 0)
 	}
 	return rt
 }
 func (self *RadixDriver) Expire(key string, second int) {
-	err := self.pool.Do(radix.Cmd(nil, "EXPIRE", key, gconv.IntString(second)))
+	err := self.do(radix.Cmd(nil, "EXPIRE", key, gconv.IntString(second)))
 	MiaError.CheckError(err)
 }
 func (self *RadixDriver) ExpireAt(key string, at time.Time) {
-	err := self.pool.Do(radix.Cmd(nil, "EXPIREAT", key, gconv.Int64String(at.Unix())))
+	err := self.do(radix.Cmd(nil, "EXPIREAT", key, gconv.Int64String(at.Unix())))
 	MiaError.CheckError(err)
 }
 
 func (self *RadixDriver) Pexpire(key string, duration time.Duration) {
-	err := self.pool.Do(radix.Cmd(nil, "PEXPIRE", key, gconv.Int64String(duration.Milliseconds())))
+	err := self.do(radix.Cmd(nil, "PEXPIRE", key, gconv.Int64String(duration.Milliseconds())))
 	MiaError.CheckError(err)
 }
 
 func (self *RadixDriver) PexpireAt(key string, at time.Time) {
-	err := self.pool.Do(radix.Cmd(nil, "PEXPIREAT", key, gconv.Int64String(at.UnixNano()/int64(time.Millisecond))))
+	err := self.do(radix.Cmd(nil, "PEXPIREAT", key, gconv.Int64String(at.UnixNano()/int64(time.Millisecond))))
 	MiaError.CheckError(err)
 }
 
 func (self *RadixDriver) Randomkey() (key string) {
 	data := radix.MaybeNil{Rcv: &key}
-	err := self.pool.Do(radix.Cmd(&data, "RANDOMKEY"))
+	err := self.do(radix.Cmd(&data, "RANDOMKEY"))
 	MiaError.CheckError(err)
 	return
 }
 
 func (self *RadixDriver) Rename(oldKey string, newKey string) (err error) {
-	return self.pool.Do(radix.Cmd(nil, "RENAME", oldKey, newKey))
+	return self.do(radix.Cmd(nil, "RENAME", oldKey, newKey))
 }
 func (self *RadixDriver) RenameNX(oldKey string, newKey string) (done bool, err error) {
 	data := radix.MaybeNil{Rcv: &done}
-	err = self.pool.Do(radix.Cmd(&data, "RENAMENX", oldKey, newKey))
+	err = self.do(radix.Cmd(&data, "RENAMENX", oldKey, newKey))
 	return
 }
 func CreateRedis(config *RedisConfig) (result *RadixDriver) {
@@ -698,8 +752,11 @@ func PingPongRedisServer(result *RadixDriver, ctx context.Context) {
 					if resulttt.IsCheckReconnect {
 						result, err := resulttt.PingPong()
 						if err != nil && result != true {
-							MiaLog.CError("mysql connect fail,err:", err)
-							MiaLog.CInfo("reconnect beginning...")
+							redisLog.Error("mysql connect fail,err:", err)
+							redisLog.Info("reconnect beginning...")
+							if m := resulttt.loadInstrument().metrics; m != nil {
+								m.IncReconnect(resulttt.Config.Addr)
+							}
 							resulttt.IsCheckReconnect = false
 							resulttt.ReConnect(resulttt.Config)
 						}
@@ -0,0 +1,350 @@
+package DB
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	gconv "github.com/og/x/conv"
+)
+
+// ErrTxAborted is returned by a transaction whose watched keys were modified before EXEC.
+var ErrTxAborted = errors.New("redis: transaction aborted, watched key modified")
+
+var queuedReply = []byte("+QUEUED\r\n")
+
+// BatchCmd is a command queued on a Batch, its reply is decoded into the
+// receiver given when it was queued and its own error is kept in Err.
+type BatchCmd struct {
+	Name string
+	Err  error
+	// Nil is true when redis replied nil, e.g. GET of a missing key.
+	Nil bool
+
+	action radix.CmdAction
+	rcv    interface{}
+}
+
+// replyErr returns the error carried by an error reply, or nil for any other reply.
+func replyErr(rm resp2.RawMessage) error {
+	if len(rm) == 0 || rm[0] != resp2.ErrorPrefix[0] {
+		return nil
+	}
+	var respErr resp2.Error
+	if err := rm.UnmarshalInto(&respErr); err != nil {
+		return err
+	}
+	return respErr
+}
+
+func (c *BatchCmd) setReply(rm resp2.RawMessage) {
+	if c.Err = replyErr(rm); c.Err != nil {
+		return
+	}
+	if rm.IsNil() {
+		c.Nil = true
+		return
+	}
+	if c.rcv != nil {
+		c.Err = rm.UnmarshalInto(resp2.Any{I: c.rcv})
+	}
+}
+
+// Batch queues commands and sends them to redis in a single round trip,
+// either as a plain pipeline (Exec) or wrapped in MULTI/EXEC (ExecTx).
+// A Batch is not safe for concurrent use and should be executed only once.
+type Batch struct {
+	r    *RadixDriver
+	cmds []*BatchCmd
+}
+
+// NewBatch returns an empty batch running on the pool of r.
+func (r *RadixDriver) NewBatch() *Batch {
+	return &Batch{r: r}
+}
+
+// Len returns the number of queued commands.
+func (b *Batch) Len() int {
+	return len(b.cmds)
+}
+
+// Cmds returns the queued commands in order.
+func (b *Batch) Cmds() []*BatchCmd {
+	return b.cmds
+}
+
+// Cmd queues a raw command, its reply is decoded into rcv like radix.Cmd.
+func (b *Batch) Cmd(rcv interface{}, cmd string, args ...string) *BatchCmd {
+	return b.add(rcv, cmd, radix.Cmd(nil, cmd, args...))
+}
+
+// FlatCmd queues a raw command whose args are flattened like radix.FlatCmd.
+func (b *Batch) FlatCmd(rcv interface{}, cmd, key string, args ...interface{}) *BatchCmd {
+	return b.add(rcv, cmd, radix.FlatCmd(nil, cmd, key, args...))
+}
+
+func (b *Batch) add(rcv interface{}, name string, action radix.CmdAction) *BatchCmd {
+	c := &BatchCmd{Name: name, action: action, rcv: rcv}
+	b.cmds = append(b.cmds, c)
+	return c
+}
+
+// The typed helpers below follow the key handling of RadixDriver: Set, Get,
+// Incr, Delete and ZAdd add Config.Prefix like the RadixDriver methods of the
+// same name, while HSet, HGetAll, SAdd and Expire take the full key, like the
+// hashes and sets of SaveToRedis, LoadFromRedis and SaveSliceToRedisSet.
+
+// Set queues SET, or SETEX when secondsLifetime > 0.
+func (b *Batch) Set(key string, value interface{}, secondsLifetime int64) *BatchCmd {
+	if secondsLifetime > 0 {
+		return b.FlatCmd(nil, SETEX, b.r.Config.Prefix+key, secondsLifetime, value)
+	}
+	return b.FlatCmd(nil, SET, b.r.Config.Prefix+key, value)
+}
+
+// Get queues GET, a missing key leaves val untouched and sets Nil.
+func (b *Batch) Get(key string, val *string) *BatchCmd {
+	return b.Cmd(val, GET, b.r.Config.Prefix+key)
+}
+
+// Incr queues INCR and stores the new value into val when not nil.
+func (b *Batch) Incr(key string, val *int64) *BatchCmd {
+	return b.Cmd(val, INCR, b.r.Config.Prefix+key)
+}
+
+// Delete queues DEL.
+func (b *Batch) Delete(key string) *BatchCmd {
+	return b.Cmd(nil, DEL, b.r.Config.Prefix+key)
+}
+
+// HSet queues HSET of a single field, key is not prefixed.
+func (b *Batch) HSet(key, field, value string) *BatchCmd {
+	return b.Cmd(nil, HSET, key, field, value)
+}
+
+// HSetStruct queues one HSET per exported field of the struct pointed by info,
+// with the codec of SaveToRedis. key is not prefixed.
+func (b *Batch) HSetStruct(key string, info interface{}) {
+	dataStruct := reflect.Indirect(reflect.ValueOf(info))
+	dataStructType := dataStruct.Type()
+	for i := 0; i < dataStructType.NumField(); i++ {
+		fieldType := dataStructType.Field(i)
+		fieldValue := dataStruct.Field(i)
+
+		switch fieldType.Type.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+			str := strconv.FormatInt(fieldValue.Int(), 10)
+			b.HSet(key, fieldType.Name, str)
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			str := strconv.FormatUint(fieldValue.Uint(), 10)
+			b.HSet(key, fieldType.Name, str)
+		case reflect.Float32, reflect.Float64:
+			str := strconv.FormatFloat(fieldValue.Float(), 'f', -1, 64)
+			b.HSet(key, fieldType.Name, str)
+
+		case reflect.String:
+			b.HSet(key, fieldType.Name, fieldValue.String())
+		//时间类型
+		case reflect.Struct:
+			str := strconv.FormatInt(fieldValue.Interface().(time.Time).Unix(), 10)
+			b.HSet(key, fieldType.Name, str)
+		case reflect.Bool:
+			if fieldValue.Bool() {
+				b.HSet(key, fieldType.Name, gconv.IntString(1))
+			} else {
+				b.HSet(key, fieldType.Name, gconv.IntString(0))
+			}
+		case reflect.Slice:
+			if fieldType.Type.Elem().Kind() == reflect.Uint8 {
+				b.HSet(key, fieldType.Name, string(fieldValue.Interface().([]byte)))
+			}
+		}
+	}
+}
+
+// HGetAll queues HGETALL, key is not prefixed.
+func (b *Batch) HGetAll(key string, val *map[string]string) *BatchCmd {
+	return b.Cmd(val, HGETALL, key)
+}
+
+// SAdd queues SADD, key is not prefixed.
+func (b *Batch) SAdd(key string, members ...string) *BatchCmd {
+	return b.Cmd(nil, SADD, append([]string{key}, members...)...)
+}
+
+// ZAdd queues ZADD of a single member.
+func (b *Batch) ZAdd(key string, score string, member string) *BatchCmd {
+	return b.Cmd(nil, ZADD, b.r.Config.Prefix+key, score, member)
+}
+
+// Expire queues EXPIRE, key is not prefixed.
+func (b *Batch) Expire(key string, second int) *BatchCmd {
+	return b.Cmd(nil, EXPIRE, key, strconv.Itoa(second))
+}
+
+// batchEncoder writes several commands with a single Encode call,
+// so they are flushed to the connection together.
+type batchEncoder []radix.CmdAction
+
+func (e batchEncoder) MarshalRESP(w io.Writer) error {
+	for _, c := range e {
+		if err := c.MarshalRESP(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exec sends every queued command as one pipeline. Each BatchCmd carries
+// its own reply and error; the returned error is a connection error or
+// else the first command error.
+func (b *Batch) Exec() ([]*BatchCmd, error) {
+	if len(b.cmds) == 0 {
+		return b.cmds, nil
+	}
+	err := b.r.doNamed("PIPELINE", radix.WithConn("", b.runPipeline))
+	if err != nil {
+		return b.cmds, err
+	}
+	return b.cmds, b.firstErr()
+}
+
+// ExecTx sends every queued command wrapped in MULTI/EXEC so they are applied atomically.
+func (b *Batch) ExecTx() ([]*BatchCmd, error) {
+	if len(b.cmds) == 0 {
+		return b.cmds, nil
+	}
+	err := b.r.doNamed("MULTI", radix.WithConn("", b.runTx))
+	if err != nil {
+		return b.cmds, err
+	}
+	return b.cmds, b.firstErr()
+}
+
+// WatchTx implements optimistic locking: keys are WATCHed on a dedicated
+// connection, fn may read them through conn and queue writes on the batch,
+// then the batch runs as MULTI/EXEC. ErrTxAborted is returned when one of
+// the keys changed meanwhile, the caller is expected to retry.
+// keys get Config.Prefix like the Batch helpers, reads through conn do not.
+// fn must not close conn; returning an error from fn discards the batch.
+func (r *RadixDriver) WatchTx(keys []string, fn func(conn radix.Conn, b *Batch) error) ([]*BatchCmd, error) {
+	watched := make([]string, len(keys))
+	for i, key := range keys {
+		watched[i] = r.Config.Prefix + key
+	}
+	b := r.NewBatch()
+	err := r.doNamed("MULTI", radix.WithConn("", func(conn radix.Conn) error {
+		if err := conn.Do(radix.Cmd(nil, "WATCH", watched...)); err != nil {
+			return err
+		}
+		if err := fn(conn, b); err != nil {
+			if unwatchErr := conn.Do(radix.Cmd(nil, "UNWATCH")); unwatchErr != nil {
+				return unwatchErr
+			}
+			return err
+		}
+		if len(b.cmds) == 0 {
+			return conn.Do(radix.Cmd(nil, "UNWATCH"))
+		}
+		return b.runTx(conn)
+	}))
+	if err != nil {
+		return b.cmds, err
+	}
+	return b.cmds, b.firstErr()
+}
+
+func (b *Batch) firstErr() error {
+	for _, c := range b.cmds {
+		if c.Err != nil {
+			return fmt.Errorf("%s: %w", c.Name, c.Err)
+		}
+	}
+	return nil
+}
+
+func (b *Batch) encoder() batchEncoder {
+	enc := make(batchEncoder, 0, len(b.cmds)+2)
+	for _, c := range b.cmds {
+		enc = append(enc, c.action)
+	}
+	return enc
+}
+
+func (b *Batch) runPipeline(conn radix.Conn) error {
+	if err := conn.Encode(b.encoder()); err != nil {
+		return err
+	}
+	for _, c := range b.cmds {
+		var rm resp2.RawMessage
+		if err := conn.Decode(&rm); err != nil {
+			return err
+		}
+		c.setReply(rm)
+	}
+	return nil
+}
+
+func (b *Batch) runTx(conn radix.Conn) error {
+	enc := append(batchEncoder{radix.Cmd(nil, "MULTI")}, b.encoder()...)
+	enc = append(enc, radix.Cmd(nil, "EXEC"))
+	if err := conn.Encode(enc); err != nil {
+		return err
+	}
+
+	var rm resp2.RawMessage
+	if err := conn.Decode(&rm); err != nil {
+		return err
+	}
+	multiErr := replyErr(rm)
+	// commands rejected while queueing make redis abort EXEC
+	for _, c := range b.cmds {
+		if err := conn.Decode(&rm); err != nil {
+			return err
+		}
+		if string(rm) != string(queuedReply) {
+			c.setReply(rm)
+		}
+	}
+
+	if err := conn.Decode(&rm); err != nil {
+		return err
+	}
+	if multiErr != nil {
+		return multiErr
+	}
+	if rm.IsNil() {
+		return ErrTxAborted
+	}
+	if err := replyErr(rm); err != nil {
+		return err
+	}
+	return rm.UnmarshalInto(execReply(b.cmds))
+}
+
+// execReply dispatches the elements of an EXEC reply to their commands.
+type execReply []*BatchCmd
+
+func (e execReply) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	if ah.N != len(e) {
+		return fmt.Errorf("redis: EXEC returned %d replies for %d commands", ah.N, len(e))
+	}
+	for _, c := range e {
+		var rm resp2.RawMessage
+		if err := rm.UnmarshalRESP(br); err != nil {
+			return err
+		}
+		c.setReply(rm)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package DB
 const (
 	GET = "GET"
 	SET = "SET"
+	SETEX = "SETEX"
 	INCR = "INCR"
 	DECR = "DECR"
 	INCRBY = "INCRBY"
@@ -20,6 +21,7 @@ const (
 	HSETNX = "HSETNX"
 	HINCRBY = "HINCRBY"
 	HDEL = "HDEL"
+	HGETALL = "HGETALL"
 	HLEN = "HLEN"
 	HEXISTS = "HEXISTS"
 	HINCRBYFLOAT = "HINCRBYFLOAT"
@@ -37,5 +39,8 @@ const (
 	RANDOMKEY = "RANDOMKEY"
 	RENAME = "RENAME"
 	RENAMENX = "RENAMENX"
+
+	SADD = "SADD"
+	ZADD = "ZADD"
 )
 
@@ -0,0 +1,423 @@
+package DB
+
+import (
+	"MiaGame/Library/MiaLog"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// JobHandler processes a job, returning an error (or panicking) schedules a retry.
+// ctx is cancelled when the visibility timeout of the job elapses or the queue stops.
+type JobHandler func(ctx context.Context, job *Job) error
+
+// Job is a unit of work stored in the queue.
+type Job struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`    // name of the handler
+	Payload   string `json:"payload"` // opaque to the queue
+	Attempt   int    `json:"attempt"` // failed attempts so far
+	RunAt     int64  `json:"run_at"`  // unix milliseconds the job is due
+	Every     int64  `json:"every"`   // milliseconds between runs of a recurring job, 0 runs once
+	LastError string `json:"last_error,omitempty"`
+}
+
+// JobQueueConfig configures a JobQueue, zero values take the defaults.
+type JobQueueConfig struct {
+	// Name namespaces the redis keys of the queue. Defaults to "jobs".
+	Name string
+	// Concurrency is the max number of jobs handled at once by Run. Defaults to 4.
+	Concurrency int
+	// VisibilityTimeout is how long a claimed job stays hidden from other workers,
+	// a job not acknowledged in time is delivered again. Defaults to 30 seconds.
+	VisibilityTimeout time.Duration
+	// PollInterval between two claims when the queue is idle. Defaults to 1 second.
+	PollInterval time.Duration
+	// MaxRetries before a failing job is moved to the dead-letter set. Defaults to 5.
+	// A job not acknowledged before its visibility timeout counts as a failed attempt,
+	// so a job crashing its worker dies too.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on every attempt. Defaults to 1 second.
+	RetryBackoff time.Duration
+	// MaxBackoff caps the retry delay. Defaults to 10 minutes.
+	MaxBackoff time.Duration
+}
+
+// JobQueue is a durable delayed and recurring job queue built on redis sorted sets:
+// due jobs wait in "<name>:delayed", claimed ones in "<name>:inflight" until acknowledged,
+// exhausted ones in "<name>:dead", all scored by unix milliseconds, and the job bodies
+// in the hash "<name>:jobs". Delivery is at-least-once, handlers should be idempotent.
+type JobQueue struct {
+	r        *RadixDriver
+	cfg      JobQueueConfig
+	mutex    sync.RWMutex
+	handlers map[string]JobHandler
+
+	delayedKey  string
+	inflightKey string
+	deadKey     string
+	jobsKey     string
+}
+
+// claimScript counts a failed attempt on every expired in-flight job and moves it
+// back to the delayed set, or to the dead-letter set past ARGV[4] attempts, then
+// moves up to ARGV[3] due jobs to the in-flight set and returns their ids and bodies.
+var claimScript = radix.NewEvalScript(4, `
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	local body = redis.call('HGET', KEYS[3], id)
+	if body then
+		local ok, job = pcall(cjson.decode, body)
+		if ok and type(job) == 'table' then
+			job.attempt = (tonumber(job.attempt) or 0) + 1
+			job.last_error = 'visibility timeout expired'
+			redis.call('HSET', KEYS[3], id, cjson.encode(job))
+			if job.attempt > tonumber(ARGV[4]) then
+				redis.call('ZADD', KEYS[4], ARGV[1], id)
+			else
+				redis.call('ZADD', KEYS[1], ARGV[1], id)
+			end
+		else
+			redis.call('ZADD', KEYS[1], ARGV[1], id)
+		end
+	end
+end
+local result = {}
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	local body = redis.call('HGET', KEYS[3], id)
+	if body then
+		redis.call('ZADD', KEYS[2], ARGV[2], id)
+		table.insert(result, id)
+		table.insert(result, body)
+	end
+end
+return result
+`)
+
+// ackScript settles a claimed job only if the claim is still ours (ARGV[2] is
+// the deadline it was claimed with): "done" drops it, "retry" reschedules it
+// at ARGV[4], "dead" moves it to the dead-letter set.
+var ackScript = radix.NewEvalScript(4, `
+if tonumber(redis.call('ZSCORE', KEYS[2], ARGV[1])) ~= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+if ARGV[3] == 'done' then
+	redis.call('HDEL', KEYS[3], ARGV[1])
+elseif ARGV[3] == 'retry' then
+	redis.call('HSET', KEYS[3], ARGV[1], ARGV[5])
+	redis.call('ZADD', KEYS[1], ARGV[4], ARGV[1])
+else
+	redis.call('HSET', KEYS[3], ARGV[1], ARGV[5])
+	redis.call('ZADD', KEYS[4], ARGV[4], ARGV[1])
+end
+return 1
+`)
+
+// NewJobQueue returns a queue stored in r, register handlers with Handle and start workers with Run.
+func NewJobQueue(r *RadixDriver, cfg JobQueueConfig) *JobQueue {
+	if cfg.Name == "" {
+		cfg.Name = "jobs"
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 30 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 10 * time.Minute
+	}
+	name := r.Config.Prefix + cfg.Name
+	return &JobQueue{
+		r:           r,
+		cfg:         cfg,
+		handlers:    make(map[string]JobHandler),
+		delayedKey:  name + ":delayed",
+		inflightKey: name + ":inflight",
+		deadKey:     name + ":dead",
+		jobsKey:     name + ":jobs",
+	}
+}
+
+// Handle registers the handler of the jobs called name.
+func (q *JobQueue) Handle(name string, h JobHandler) {
+	q.mutex.Lock()
+	q.handlers[name] = h
+	q.mutex.Unlock()
+}
+
+// Enqueue schedules the job name to run once after delay and returns its id.
+func (q *JobQueue) Enqueue(name, payload string, delay time.Duration) (string, error) {
+	return q.EnqueueAt(name, payload, time.Now().Add(delay))
+}
+
+// EnqueueAt schedules the job name to run once at the given time and returns its id.
+func (q *JobQueue) EnqueueAt(name, payload string, at time.Time) (string, error) {
+	return q.add(&Job{Name: name, Payload: payload, RunAt: unixMilli(at)})
+}
+
+// EnqueueEvery schedules the job name to run every interval, starting one interval from now.
+// A recurring job is rescheduled after each successful run until cancelled.
+func (q *JobQueue) EnqueueEvery(name, payload string, every time.Duration) (string, error) {
+	if every <= 0 {
+		return "", errors.New("jobqueue: interval must be positive")
+	}
+	return q.add(&Job{
+		Name:    name,
+		Payload: payload,
+		RunAt:   unixMilli(time.Now().Add(every)),
+		Every:   every.Milliseconds(),
+	})
+}
+
+func (q *JobQueue) add(job *Job) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	job.ID = hex.EncodeToString(id)
+	body, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	b := q.r.NewBatch()
+	b.Cmd(nil, HSET, q.jobsKey, job.ID, string(body))
+	b.Cmd(nil, ZADD, q.delayedKey, strconv.FormatInt(job.RunAt, 10), job.ID)
+	if _, err = b.ExecTx(); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Cancel removes a pending, in-flight or dead job. A running handler is not interrupted
+// but the job will not be delivered again.
+func (q *JobQueue) Cancel(id string) error {
+	b := q.r.NewBatch()
+	b.Cmd(nil, "ZREM", q.delayedKey, id)
+	b.Cmd(nil, "ZREM", q.inflightKey, id)
+	b.Cmd(nil, "ZREM", q.deadKey, id)
+	b.Cmd(nil, HDEL, q.jobsKey, id)
+	_, err := b.ExecTx()
+	return err
+}
+
+// DeadJobs returns the jobs which exhausted their retries.
+func (q *JobQueue) DeadJobs() ([]*Job, error) {
+	var ids []string
+	if err := q.r.do(radix.Cmd(&ids, "ZRANGE", q.deadKey, "0", "-1")); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var bodies []string
+	if err := q.r.do(radix.Cmd(&bodies, "HMGET", append([]string{q.jobsKey}, ids...)...)); err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(bodies))
+	for _, body := range bodies {
+		if body == "" {
+			continue
+		}
+		job := new(Job)
+		if err := json.Unmarshal([]byte(body), job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RequeueDead moves a dead job back to the queue with its attempts reset, due now.
+func (q *JobQueue) RequeueDead(id string) error {
+	var body string
+	mn := radix.MaybeNil{Rcv: &body}
+	if err := q.r.do(radix.Cmd(&mn, HGET, q.jobsKey, id)); err != nil {
+		return err
+	}
+	if mn.Nil {
+		return fmt.Errorf("jobqueue: job %s not found", id)
+	}
+	job := new(Job)
+	if err := json.Unmarshal([]byte(body), job); err != nil {
+		return err
+	}
+	job.Attempt = 0
+	job.LastError = ""
+	job.RunAt = unixMilli(time.Now())
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	b := q.r.NewBatch()
+	b.Cmd(nil, "ZREM", q.deadKey, id)
+	b.Cmd(nil, HSET, q.jobsKey, id, string(data))
+	b.Cmd(nil, ZADD, q.delayedKey, strconv.FormatInt(job.RunAt, 10), id)
+	_, err = b.ExecTx()
+	return err
+}
+
+// Run claims and handles due jobs with at most Concurrency handlers at once,
+// it blocks until ctx is done and the running handlers returned.
+func (q *JobQueue) Run(ctx context.Context) {
+	sem := make(chan struct{}, q.cfg.Concurrency)
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+LOOP:
+	for {
+		free := cap(sem) - len(sem)
+		claimed := 0
+		if free > 0 {
+			jobs, deadline, err := q.claim(free)
+			if err != nil {
+				redisLog.Error("jobqueue: claim error:", err)
+			}
+			claimed = len(jobs)
+			for _, job := range jobs {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func(job *Job) {
+					defer func() {
+						<-sem
+						wg.Done()
+					}()
+					q.process(ctx, job, deadline)
+				}(job)
+			}
+		}
+		// keep draining while the queue is busy, otherwise wait for the next tick
+		if claimed > 0 && claimed == free {
+			select {
+			case <-ctx.Done():
+				break LOOP
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			break LOOP
+		}
+	}
+	wg.Wait()
+}
+
+func (q *JobQueue) claim(limit int) ([]*Job, int64, error) {
+	now := time.Now()
+	deadline := unixMilli(now.Add(q.cfg.VisibilityTimeout))
+	var reply []string
+	err := q.r.doNamed("EVAL", claimScript.Cmd(&reply,
+		q.delayedKey, q.inflightKey, q.jobsKey, q.deadKey,
+		strconv.FormatInt(unixMilli(now), 10), strconv.FormatInt(deadline, 10), strconv.Itoa(limit),
+		strconv.Itoa(q.cfg.MaxRetries)))
+	if err != nil {
+		return nil, 0, err
+	}
+	jobs := make([]*Job, 0, len(reply)/2)
+	for i := 0; i+1 < len(reply); i += 2 {
+		job := new(Job)
+		if err := json.Unmarshal([]byte(reply[i+1]), job); err != nil {
+			redisLog.Error("jobqueue: bad job ", reply[i], ":", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, deadline, nil
+}
+
+func (q *JobQueue) process(ctx context.Context, job *Job, deadline int64) {
+	ctx, cancel := context.WithDeadline(ctx, time.Unix(0, deadline*int64(time.Millisecond)))
+	defer cancel()
+
+	err := q.call(ctx, job)
+	now := time.Now()
+	switch {
+	case err == nil && job.Every > 0:
+		job.Attempt = 0
+		job.LastError = ""
+		job.RunAt += job.Every
+		if job.RunAt < unixMilli(now) {
+			job.RunAt = unixMilli(now.Add(time.Duration(job.Every) * time.Millisecond))
+		}
+		q.ack(job, deadline, "retry", job.RunAt)
+	case err == nil:
+		q.ack(job, deadline, "done", 0)
+	default:
+		job.Attempt++
+		job.LastError = err.Error()
+		if job.Attempt > q.cfg.MaxRetries {
+			redisLog.Error("jobqueue: job ", job.Name, " ", job.ID, " is dead after ", job.Attempt, " attempts:", err)
+			q.ack(job, deadline, "dead", unixMilli(now))
+			return
+		}
+		redisLog.Warn("jobqueue: job ", job.Name, " ", job.ID, " attempt ", job.Attempt, " failed:", err)
+		q.ack(job, deadline, "retry", unixMilli(now.Add(q.backoff(job.Attempt))))
+	}
+}
+
+// call runs the handler of job, converting a panic into an error.
+func (q *JobQueue) call(ctx context.Context, job *Job) (err error) {
+	q.mutex.RLock()
+	h, ok := q.handlers[job.Name]
+	q.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("jobqueue: no handler for %q", job.Name)
+	}
+	defer MiaLog.RecoverError(&err)
+	return h(ctx, job)
+}
+
+func (q *JobQueue) ack(job *Job, deadline int64, action string, score int64) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		redisLog.Error("jobqueue: encode job ", job.ID, ":", err)
+		return
+	}
+	var settled int
+	err = q.r.doNamed("EVAL", ackScript.Cmd(&settled,
+		q.delayedKey, q.inflightKey, q.jobsKey, q.deadKey,
+		job.ID, strconv.FormatInt(deadline, 10), action, strconv.FormatInt(score, 10), string(body)))
+	if err != nil {
+		redisLog.Error("jobqueue: ack job ", job.ID, ":", err)
+	} else if settled == 0 {
+		redisLog.Warn("jobqueue: job ", job.ID, " outlived its visibility timeout and was delivered again")
+	}
+}
+
+func (q *JobQueue) backoff(attempt int) time.Duration {
+	d := q.cfg.RetryBackoff
+	for i := 1; i < attempt && d < q.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.cfg.MaxBackoff {
+		d = q.cfg.MaxBackoff
+	}
+	return d
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,190 @@
+package DB
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/trace"
+)
+
+// RedisMetrics receives the instrumentation of a RadixDriver,
+// MiaPrometheus.RedisCollector implements it.
+type RedisMetrics interface {
+	// ObserveCommand is called after every command, pipeline or transaction.
+	ObserveCommand(addr, command string, elapsed time.Duration, err error)
+	// ObservePool reports the configured size of the pool and its idle connections.
+	ObservePool(addr string, size, available int)
+	// ObservePoolWait reports how long a command waited to check a connection out
+	// of the pool. Commands radix pipelines implicitly share connections and
+	// are not reported.
+	ObservePoolWait(addr string, wait time.Duration)
+	// IncConnCreated counts new connections, reason "pool empty" means the pool
+	// had no idle connection after a wait and dialed an extra one.
+	IncConnCreated(addr, reason string)
+	// IncReconnect counts the reconnections triggered by PingPongRedisServer.
+	IncReconnect(addr string)
+}
+
+// RedactRule rewrites the parts of a key matching Pattern with Replace
+// (regexp.ReplaceAllString syntax) before the key is logged.
+type RedactRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+}
+
+// SlowLogConfig enables logging of slow commands to the "redis" MiaLog module.
+type SlowLogConfig struct {
+	// Threshold above which a command is logged, 0 disables the slow log.
+	Threshold time.Duration `yaml:"threshold"`
+	// RedactRules are applied in order to every logged key.
+	RedactRules []RedactRule `yaml:"redact_rules"`
+}
+
+type compiledRedactRule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+type redisInstrument struct {
+	metrics       RedisMetrics
+	slowThreshold time.Duration
+	redactRules   []compiledRedactRule
+}
+
+var noInstrument = &redisInstrument{}
+
+func (r *RadixDriver) loadInstrument() *redisInstrument {
+	if i, ok := r.instrument.Load().(*redisInstrument); ok {
+		return i
+	}
+	return noInstrument
+}
+
+// SetMetrics starts reporting commands, pool and reconnect statistics to m,
+// nil stops it. Drivers obtained afterwards through Database inherit it.
+func (r *RadixDriver) SetMetrics(m RedisMetrics) {
+	i := *r.loadInstrument()
+	i.metrics = m
+	r.instrument.Store(&i)
+}
+
+// SetSlowLog logs every command slower than cfg.Threshold with its keys redacted by cfg.RedactRules.
+func (r *RadixDriver) SetSlowLog(cfg SlowLogConfig) error {
+	rules := make([]compiledRedactRule, 0, len(cfg.RedactRules))
+	for _, rule := range cfg.RedactRules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("redis: invalid redact rule %q: %w", rule.Pattern, err)
+		}
+		rules = append(rules, compiledRedactRule{re: re, replace: rule.Replace})
+	}
+	i := *r.loadInstrument()
+	i.slowThreshold = cfg.Threshold
+	i.redactRules = rules
+	r.instrument.Store(&i)
+	return nil
+}
+
+func (i *redisInstrument) redact(keys []string) []string {
+	if len(i.redactRules) == 0 {
+		return keys
+	}
+	redacted := make([]string, len(keys))
+	for k, key := range keys {
+		for _, rule := range i.redactRules {
+			key = rule.re.ReplaceAllString(key, rule.replace)
+		}
+		redacted[k] = key
+	}
+	return redacted
+}
+
+// do runs a on the pool, recording it when instrumentation is enabled.
+func (r *RadixDriver) do(a radix.Action) error {
+	return r.doNamed("", a)
+}
+
+// doNamed is do for actions which can't tell their command name, like pipelines or scripts.
+func (r *RadixDriver) doNamed(name string, a radix.Action) error {
+	i := r.loadInstrument()
+	if i.metrics == nil && i.slowThreshold <= 0 {
+		return r.getPool().Do(a)
+	}
+
+	start := time.Now()
+	var err error
+	if i.metrics != nil && reflect.TypeOf(a) != pipelinedType {
+		w := &poolWaitAction{Action: a}
+		err = r.getPool().Do(w)
+		if !w.ran.IsZero() {
+			i.metrics.ObservePoolWait(r.Config.Addr, w.ran.Sub(start))
+		}
+	} else {
+		err = r.getPool().Do(a)
+	}
+	elapsed := time.Since(start)
+
+	if name == "" {
+		name = actionName(a)
+	}
+	if i.metrics != nil {
+		i.metrics.ObserveCommand(r.Config.Addr, name, elapsed, err)
+	}
+	if i.slowThreshold > 0 && elapsed >= i.slowThreshold {
+		redisLog.Warnf("redis slow command %s keys %v took %s", name, i.redact(a.Keys()), elapsed)
+	}
+	return err
+}
+
+// pipelinedType is the type of radix.Cmd, the only actions the pool pipelines
+// instead of checking a connection out for them.
+var pipelinedType = reflect.TypeOf(radix.Cmd(nil, "PING"))
+
+// poolWaitAction records when the pool runs the wrapped action on a connection.
+// It hides CmdAction so it must not wrap actions the pool would pipeline.
+type poolWaitAction struct {
+	radix.Action
+	ran time.Time
+}
+
+func (a *poolWaitAction) Run(c radix.Conn) error {
+	a.ran = time.Now()
+	return a.Action.Run(c)
+}
+
+// actionName extracts the command name of a radix.Cmd/FlatCmd action.
+func actionName(a radix.Action) string {
+	s, ok := a.(fmt.Stringer)
+	if !ok {
+		return "UNKNOWN"
+	}
+	// radix renders commands as ["NAME" "arg" ...]
+	str := strings.TrimPrefix(s.String(), "[")
+	if idx := strings.IndexAny(str, " ]"); idx >= 0 {
+		str = str[:idx]
+	}
+	if name, err := strconv.Unquote(str); err == nil {
+		str = name
+	}
+	return strings.ToUpper(str)
+}
+
+func (r *RadixDriver) poolTrace() trace.PoolTrace {
+	return trace.PoolTrace{
+		ConnCreated: func(c trace.PoolConnCreated) {
+			if m := r.loadInstrument().metrics; m != nil && c.Err == nil {
+				m.IncConnCreated(c.Addr, string(c.Reason))
+			}
+		},
+		DoCompleted: func(c trace.PoolDoCompleted) {
+			if m := r.loadInstrument().metrics; m != nil {
+				m.ObservePool(c.Addr, c.PoolSize, c.AvailCount)
+			}
+		},
+	}
+}
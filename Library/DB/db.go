@@ -47,10 +47,16 @@ func (appconfig  *GormDB) GetMysqlConfig() *MysqlConfig{
 }
 
 // 本方法会给GormClient赋值，多次调用GormClient指向最后一次调用的GormDB
+// encryptkey 可以是任意长度的口令，密码用 MiaCrypt.StringEncryptPassphrase 加密，解密失败时返回 nil
 func InitGormDB(dbConfig *MysqlConfig,ctx context.Context, encryptkey string) *GormDB {
 	MiaLog.CInfo("starting db")
 	if encryptkey!="" {
-		dbConfig.Password ,_= MiaCrypt.StringDecrypt(dbConfig.Password,encryptkey)
+		password, err := MiaCrypt.StringDecrypt(dbConfig.Password,encryptkey)
+		if err != nil {
+			MiaLog.Errorf("fail to decrypt the database password: %v\n", err)
+			return nil
+		}
+		dbConfig.Password = password
 	}
 	GormClient := &GormDB{
 		dbConfig:&MysqlConfig{
@@ -272,11 +278,7 @@ LOOP:
 
 //xi详细执行内容
 func (p *GormDB) autoCreate(it interface{}) {
-	defer func() { // 必须要先声明defer，否则不能捕获到panic异常
-		if err := recover(); err != nil {
-			fmt.Println(err) // 这里的err其实就是panic传入的内容
-		}
-	}()
+	defer MiaLog.Recover() // 必须要先声明defer，否则不能捕获到panic异常
 	if p.Client == nil  || it==nil {
 		MiaLog.CError("==================%v",it)
 		return
@@ -0,0 +1,73 @@
+package DB
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"MiaGame/Library/MiaCrypt"
+)
+
+// ErrNoColumnKeyRing is returned when an EncryptedString is stored or read before SetColumnKeyRing.
+var ErrNoColumnKeyRing = errors.New("DB: no key ring for encrypted columns")
+
+var columnKeyRing atomic.Value
+
+// SetColumnKeyRing sets the key ring of the EncryptedString columns.
+func SetColumnKeyRing(r *MiaCrypt.KeyRing) {
+	columnKeyRing.Store(r)
+}
+
+func loadColumnKeyRing() (*MiaCrypt.KeyRing, error) {
+	r, _ := columnKeyRing.Load().(*MiaCrypt.KeyRing)
+	if r == nil {
+		return nil, ErrNoColumnKeyRing
+	}
+	return r, nil
+}
+
+// EncryptedString is a string column stored encrypted with the primary key of
+// the column key ring, the key id is stored with it so rows written before a
+// rotation are still read.
+type EncryptedString string
+
+// GormDataType .
+func (EncryptedString) GormDataType() string {
+	return "text"
+}
+
+// Value implements driver.Valuer.
+func (s EncryptedString) Value() (driver.Value, error) {
+	r, err := loadColumnKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	return r.EncryptString(string(s))
+}
+
+// Scan implements sql.Scanner.
+func (s *EncryptedString) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("DB: can't scan %T into EncryptedString", src)
+	}
+	r, err := loadColumnKeyRing()
+	if err != nil {
+		return err
+	}
+	plain, err := r.DecryptString(text)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plain)
+	return nil
+}
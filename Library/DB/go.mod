@@ -20,5 +20,4 @@ replace (
 	MiaGame/Library/MiaCrypt => ../MiaCrypt
 	MiaGame/Library/MiaError => ../MiaError
 	MiaGame/Library/MiaLog => ../MiaLog
-
 )
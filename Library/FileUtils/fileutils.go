@@ -1,8 +1,6 @@
 package FileUtils
 
 import (
-	"bufio"
-	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,6 +14,8 @@ import (
 	"runtime"
 	"log"
 	"path"
+
+	"MiaGame/Library/MiaCrypt"
 )
 
 // GetCurrentAbPathByExecutable 当前执行文件目录
@@ -374,14 +374,23 @@ func MoveFile(src string, dst string) error {
 // MoveFileAfterCheckMd5 will check whether the file's md5 is equals to the param md5
 // before move the file src to dst.
 func MoveFileAfterCheckMd5(src string, dst string, md5 string) error {
+	return MoveFileAfterCheckSum(src, dst, MiaCrypt.MD5, md5)
+}
+
+// MoveFileAfterCheckSum will check whether the file's alg digest is equals to
+// the hex sum before move the file src to dst.
+func MoveFileAfterCheckSum(src string, dst string, alg MiaCrypt.HashAlgorithm, sum string) error {
 	if !IsRegularFile(src) {
-		return fmt.Errorf("move file with md5 check:%s error, is not a "+
-			"regular file", src)
+		return fmt.Errorf("move file with %s check:%s error, is not a "+
+			"regular file", alg, src)
+	}
+	m, err := SumFile(src, alg)
+	if err != nil {
+		return fmt.Errorf("move file with %s check:%s error, %v", alg, src, err)
 	}
-	m := Md5SumFile(src)
-	if m != md5 {
-		return fmt.Errorf("move file with md5 check:%s error, md5 of source "+
-			"file doesn't match against the given md5 value", src)
+	if !strings.EqualFold(m, sum) {
+		return fmt.Errorf("move file with %s check:%s error, %s of source "+
+			"file doesn't match against the given %s value", alg, src, alg, alg)
 	}
 	return MoveFile(src, dst)
 }
@@ -423,26 +432,71 @@ func IsRegularFile(name string) bool {
 
 // Md5Sum generates md5 for a given file.
 func Md5SumFile(name string) string {
-	if !IsRegularFile(name) {
-		return ""
-	}
-	f, err := os.Open(name)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-	r := bufio.NewReaderSize(f, BufferSize)
-	h := md5.New()
+	sum, _ := SumFile(name, MiaCrypt.MD5)
+	return sum
+}
 
-	_, err = io.Copy(h, r)
-	if err != nil {
-		return ""
+// SumFile generates the alg digest in hex for a given regular file,
+// see MiaCrypt.SumFile for a progress callback.
+func SumFile(name string, alg MiaCrypt.HashAlgorithm) (string, error) {
+	if !IsRegularFile(name) {
+		return "", fmt.Errorf("sum file:%s error, is not a regular file", name)
 	}
-
-	return GetMd5Sum(h, nil)
+	return MiaCrypt.SumFile(alg, name, nil)
 }
 
 // GetMd5Sum gets md5 sum as a string and appends the current hash to b.
 func GetMd5Sum(md5 hash.Hash, b []byte) string {
 	return fmt.Sprintf("%x", md5.Sum(b))
 }
+
+// EncryptFile encrypts src to dst with MiaCrypt.EncryptStream. dst is written
+// to a temporary file first and renamed, so it is complete or left untouched.
+func EncryptFile(src string, dst string, key []byte) error {
+	return transformFile(src, dst, func(w io.Writer, r io.Reader) error {
+		return MiaCrypt.EncryptStream(w, r, key)
+	})
+}
+
+// DecryptFile decrypts src, a file of EncryptFile, to dst. Nothing is written
+// to dst when src is altered or truncated.
+func DecryptFile(src string, dst string, key []byte) error {
+	return transformFile(src, dst, func(w io.Writer, r io.Reader) error {
+		return MiaCrypt.DecryptStream(w, r, key)
+	})
+}
+
+func transformFile(src string, dst string, fn func(w io.Writer, r io.Reader) error) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if err = fn(tmp, in); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -1,3 +1,12 @@
 module MiaGame/Library/FileUtils
 
 go 1.17
+
+require MiaGame/Library/MiaCrypt v0.0.0
+
+require (
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+)
+
+replace MiaGame/Library/MiaCrypt => ../MiaCrypt
@@ -1,28 +1,48 @@
 package MiaCrypt
 
 import (
-	"bytes"
 	"crypto/cipher"
 	"crypto/aes"
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
-	"io"
-	"fmt"
+	"errors"
 	"encoding/base64"
-	"crypto/rand"
 
 
 )
-func padding(plaintext []byte, blockSize int) []byte {
-	padding := blockSize - len(plaintext)%blockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(plaintext, padtext...)
-}
 
-func unPadding(origData []byte) []byte {
+var (
+	// ErrInvalidPadding is returned when the PKCS#7 padding of a legacy blob is wrong,
+	// usually a wrong key or a corrupt ciphertext.
+	ErrInvalidPadding = errors.New("MiaCrypt: invalid padding")
+	// ErrCiphertextTooShort is returned when a ciphertext can't hold its header, nonce or blocks.
+	ErrCiphertextTooShort = errors.New("MiaCrypt: ciphertext too short")
+	// ErrAuthFailed is returned when an AES-GCM ciphertext doesn't authenticate,
+	// a wrong key or an altered ciphertext.
+	ErrAuthFailed = errors.New("MiaCrypt: message authentication failed")
+	// ErrKeySize is returned by Encrypt when the key is not 16, 24 or 32 bytes,
+	// a passphrase goes to EncryptWithPassphrase instead.
+	ErrKeySize = errors.New("MiaCrypt: the key must be 16, 24 or 32 bytes")
+)
+
+// unPadding checks the padding in constant time, it doesn't tell which byte is wrong.
+func unPadding(origData []byte, blockSize int) ([]byte, error) {
 	length := len(origData)
+	if length == 0 || length%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
 	unpadding := int(origData[length-1])
-	return origData[:(length - unpadding)]
+	good := subtle.ConstantTimeLessOrEq(1, unpadding) & subtle.ConstantTimeLessOrEq(unpadding, blockSize)
+	for i := 1; i <= blockSize; i++ {
+		inPad := subtle.ConstantTimeLessOrEq(i, unpadding)
+		match := subtle.ConstantTimeByteEq(origData[length-i], byte(unpadding))
+		good &= match | (inPad ^ 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return origData[:(length - unpadding)], nil
 }
 
 func aesDecrypt(key, crypted []byte) ([]byte, error) {
@@ -31,51 +51,43 @@ func aesDecrypt(key, crypted []byte) ([]byte, error) {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(crypted) < blockSize {
+		return nil, ErrCiphertextTooShort
+	}
+	if len(crypted)%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
 	origData := make([]byte, len(crypted))
 	blockMode.CryptBlocks(origData, crypted)
-	origData = unPadding(origData)
-	return origData, nil
+	return unPadding(origData, blockSize)
 }
 
-func aesEncryptWithSalt(key, plaintext []byte) ([]byte, error) {
-	plaintext = padding(plaintext, aes.BlockSize)
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
-	iv := ciphertext[0:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
-	}
-	for i := 2; i < 8; i++ {
-		iv[i] = 1
-	}
-	cbc := cipher.NewCBCEncrypter(block, iv)
-	cbc.CryptBlocks(ciphertext[aes.BlockSize:], plaintext)
-	return ciphertext, nil
-}
 func aesDecryptWithSalt(key, ciphertext []byte) ([]byte, error) {
 	var block cipher.Block
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	if len(ciphertext) < aes.BlockSize {
-		return nil, fmt.Errorf("iciphertext too short")
+	// the iv and at least a block
+	if len(ciphertext) < 2*aes.BlockSize {
+		return nil, ErrCiphertextTooShort
+	}
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrInvalidPadding
 	}
 	iv := ciphertext[:aes.BlockSize]
-	ciphertext = ciphertext[aes.BlockSize:]
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
 	cbc := cipher.NewCBCDecrypter(block, iv)
-	cbc.CryptBlocks(ciphertext, ciphertext)
-	ciphertext = unPadding(ciphertext)
-	return ciphertext, nil
+	cbc.CryptBlocks(plaintext, ciphertext[aes.BlockSize:])
+	return unPadding(plaintext, aes.BlockSize)
 }
 
+// StringEncrypt encrypts text with AES-256-GCM, see Encrypt, and encodes it in base64.
+// key is an AES key, use StringEncryptPassphrase for a passphrase.
 func StringEncrypt(text string,key string) (string, error) {
 	pass := []byte(text)
-	xpass, err := aesEncryptWithSalt([]byte(key), pass)
+	xpass, err := Encrypt(pass, []byte(key))
 	if err == nil {
 		pass64 := base64.StdEncoding.EncodeToString(xpass)
 		return pass64, err
@@ -88,12 +100,32 @@ func GetMd5String(s string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// StringDecrypt decrypts the results of StringEncrypt and StringEncryptPassphrase,
+// the legacy AES-CBC ones included.
 func StringDecrypt(text string,key string) (string, error) {
 	bytesPass, err := base64.StdEncoding.DecodeString(text)
 	if err != nil {
 		return "", err
 	}
 	var tpass []byte
+	if maybe, certain := isKDF(bytesPass); maybe {
+		tpass, err = DecryptWithPassphrase(bytesPass, []byte(key))
+		if err == nil || certain {
+			if err != nil {
+				return "", err
+			}
+			return string(tpass), nil
+		}
+	}
+	if maybe, certain := isGCM(bytesPass); maybe {
+		tpass, err = Decrypt(bytesPass, []byte(key))
+		if err == nil || certain {
+			if err != nil {
+				return "", err
+			}
+			return string(tpass), nil
+		}
+	}
 	if isSaltPass(bytesPass) {
 		tpass, err = aesDecryptWithSalt([]byte(key), bytesPass)
 	} else {
@@ -107,6 +139,9 @@ func StringDecrypt(text string,key string) (string, error) {
 }
 
 func isSaltPass(pass []byte) bool {
+	if len(pass) < 2*aes.BlockSize {
+		return false
+	}
 	for i := 2; i < 8; i++ {
 		if pass[i] != 1 {
 			return false
@@ -0,0 +1,304 @@
+package MiaCrypt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Signature algorithms, named as in JWS.
+const (
+	RS256 = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256, used by WeChat Pay v3
+	RS512 = "RS512"
+	PS256 = "PS256" // RSASSA-PSS with SHA-256
+	ES256 = "ES256" // ECDSA P-256 with SHA-256, ASN.1 DER signatures
+	EdDSA = "EdDSA" // Ed25519
+)
+
+var (
+	// ErrVerification is returned when a signature doesn't match.
+	ErrVerification = errors.New("MiaCrypt: signature verification failed")
+	// ErrKeyType is returned when a key doesn't fit the algorithm or the PEM block.
+	ErrKeyType = errors.New("MiaCrypt: unsupported key type")
+)
+
+// ParsePrivateKeyPEM parses the first private key of data, PKCS#8, PKCS#1 RSA
+// or SEC 1 EC. Encrypted PEM blocks aren't supported.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("MiaCrypt: no private key in PEM data")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			return ParsePrivateKeyDER(block.Bytes)
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, errors.New("MiaCrypt: encrypted private keys aren't supported")
+		}
+	}
+}
+
+// ParsePrivateKeyDER parses a PKCS#8 private key, falling back to PKCS#1 and SEC 1.
+func ParsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, ErrKeyType
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("MiaCrypt: can't parse private key")
+}
+
+// ParsePublicKeyPEM parses the first public key of data, PKIX, PKCS#1 RSA or
+// the key of a certificate.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("MiaCrypt: no public key in PEM data")
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			return x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			return x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return cert.PublicKey, nil
+		}
+	}
+}
+
+// ParsePublicKeyDER parses a PKIX public key, falling back to PKCS#1.
+func ParsePublicKeyDER(der []byte) (crypto.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PublicKey(der)
+}
+
+// LoadPrivateKeyFile reads a PEM private key.
+func LoadPrivateKeyFile(file string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(data)
+}
+
+// LoadPublicKeyFile reads a PEM public key or certificate.
+func LoadPublicKeyFile(file string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKeyPEM(data)
+}
+
+// MarshalPrivateKeyPEM encodes key in a PKCS#8 "PRIVATE KEY" block.
+func MarshalPrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKeyPEM encodes key in a PKIX "PUBLIC KEY" block.
+func MarshalPublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// GenerateKeyPair returns a new key for alg: 2048-bit RSA, P-256 or Ed25519.
+func GenerateKeyPair(alg string) (crypto.Signer, error) {
+	switch alg {
+	case RS256, RS512, PS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("MiaCrypt: unknown algorithm %s", alg)
+}
+
+func signHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case RS256, PS256, ES256:
+		return crypto.SHA256, nil
+	case RS512:
+		return crypto.SHA512, nil
+	case EdDSA:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("MiaCrypt: unknown algorithm %s", alg)
+}
+
+// Sign signs msg with key, which may live in an HSM as long as it implements crypto.Signer.
+func Sign(alg string, key crypto.Signer, msg []byte) ([]byte, error) {
+	hash, err := signHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyType(alg, key.Public()); err != nil {
+		return nil, err
+	}
+	if alg == EdDSA {
+		return key.Sign(rand.Reader, msg, crypto.Hash(0))
+	}
+	h := hash.New()
+	h.Write(msg)
+	var opts crypto.SignerOpts = hash
+	if alg == PS256 {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	return key.Sign(rand.Reader, h.Sum(nil), opts)
+}
+
+// Verify checks the signature sig of msg, it returns ErrVerification when it doesn't match.
+func Verify(alg string, pub crypto.PublicKey, msg, sig []byte) error {
+	hash, err := signHash(alg)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyType(alg, pub); err != nil {
+		return err
+	}
+	var digest []byte
+	if alg != EdDSA {
+		h := hash.New()
+		h.Write(msg)
+		digest = h.Sum(nil)
+	}
+	ok := false
+	switch alg {
+	case RS256, RS512:
+		ok = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), hash, digest, sig) == nil
+	case PS256:
+		ok = rsa.VerifyPSS(pub.(*rsa.PublicKey), hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+	case ES256:
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest, sig)
+	case EdDSA:
+		ok = ed25519.Verify(pub.(ed25519.PublicKey), msg, sig)
+	}
+	if !ok {
+		return ErrVerification
+	}
+	return nil
+}
+
+func checkKeyType(alg string, pub crypto.PublicKey) error {
+	ok := false
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		ok = alg == RS256 || alg == RS512 || alg == PS256
+	case *ecdsa.PublicKey:
+		ok = alg == ES256 && key.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		ok = alg == EdDSA
+	}
+	if !ok {
+		return fmt.Errorf("%w: %T for %s", ErrKeyType, pub, alg)
+	}
+	return nil
+}
+
+// SignString is Sign with the signature encoded in base64, the encoding of
+// WeChat Pay v3 signatures.
+func SignString(alg string, key crypto.Signer, msg string) (string, error) {
+	sig, err := Sign(alg, key, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyString verifies a signature of SignString.
+func VerifyString(alg string, pub crypto.PublicKey, msg, sig string) error {
+	b, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return ErrVerification
+	}
+	return Verify(alg, pub, []byte(msg), b)
+}
+
+// RSAEncryptOAEP encrypts plaintext with RSA-OAEP. WeChat Pay v3 encrypts the
+// sensitive fields with crypto.SHA1, use crypto.SHA256 otherwise.
+func RSAEncryptOAEP(pub crypto.PublicKey, hash crypto.Hash, plaintext []byte) ([]byte, error) {
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T for RSA-OAEP", ErrKeyType, pub)
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("MiaCrypt: hash %v unavailable", hash)
+	}
+	return rsa.EncryptOAEP(hash.New(), rand.Reader, key, plaintext, nil)
+}
+
+// RSADecryptOAEP decrypts a ciphertext of RSAEncryptOAEP.
+func RSADecryptOAEP(key crypto.Signer, hash crypto.Hash, ciphertext []byte) ([]byte, error) {
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T for RSA-OAEP", ErrKeyType, key)
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("MiaCrypt: hash %v unavailable", hash)
+	}
+	plaintext, err := rsa.DecryptOAEP(hash.New(), rand.Reader, priv, ciphertext, nil)
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plaintext, nil
+}
+
+// RSAEncryptOAEPString is RSAEncryptOAEP with the ciphertext encoded in base64.
+func RSAEncryptOAEPString(pub crypto.PublicKey, hash crypto.Hash, text string) (string, error) {
+	b, err := RSAEncryptOAEP(pub, hash, []byte(text))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// RSADecryptOAEPString decrypts RSAEncryptOAEPString.
+func RSADecryptOAEPString(key crypto.Signer, hash crypto.Hash, text string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := RSADecryptOAEP(key, hash, b)
+	return string(plaintext), err
+}
@@ -0,0 +1,88 @@
+package MiaCrypt
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// ParseCertificatesPEM parses every certificate of data, in order, the leaf
+// first in a chain.
+func ParseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("MiaCrypt: no certificate in PEM data")
+	}
+	return certs, nil
+}
+
+// LoadCertificatesFile reads the certificates of a PEM file.
+func LoadCertificatesFile(file string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificatesPEM(data)
+}
+
+// LoadCertPool reads the PEM files into a pool, e.g. the roots of VerifyChain.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		certs, err := LoadCertificatesFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+	}
+	return pool, nil
+}
+
+// VerifyChain verifies chain[0] with the rest of chain as intermediates up to
+// roots, the system roots when nil. dnsName is checked when not empty.
+func VerifyChain(chain []*x509.Certificate, roots *x509.CertPool, dnsName string, usages ...x509.ExtKeyUsage) ([][]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("MiaCrypt: empty certificate chain")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		DNSName:       dnsName,
+		CurrentTime:   time.Now(),
+		KeyUsages:     usages,
+	}
+	if len(usages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	for _, cert := range chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	return chain[0].Verify(opts)
+}
+
+// CertificateSerial returns the serial number in upper case hex, the serial_no
+// of WeChat Pay v3.
+func CertificateSerial(cert *x509.Certificate) string {
+	return strings.ToUpper(hex.EncodeToString(cert.SerialNumber.Bytes()))
+}
@@ -0,0 +1,278 @@
+package MiaCrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	testKey      = []byte("0123456789abcdef")
+	testWrongKey = []byte("fedcba9876543210")
+	testIV       = []byte{7, 7, 1, 1, 1, 1, 1, 1, 7, 7, 7, 7, 7, 7, 7, 7}
+	// cheap params so the fuzzer can decrypt many of them
+	testPBKDF2   = KDF{Algorithm: KDFPBKDF2, Iterations: 1000}
+	testArgon2id = KDF{Algorithm: KDFArgon2id, Time: 1, Memory: 64, Threads: 1}
+)
+
+// pkcs7 pads like the legacy encryption did.
+func pkcs7(data []byte) []byte {
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// legacyEncrypt is the AES-CBC of the first MiaCrypt versions: without iv it
+// uses the key as iv, otherwise iv is the salt prepended to the blocks, marked
+// by its bytes 2 to 7 set to 1. blocks must already be padded.
+func legacyEncrypt(t testing.TB, key, iv, blocks []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iv == nil {
+		out := make([]byte, len(blocks))
+		cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(out, blocks)
+		return out
+	}
+	out := append([]byte(nil), iv...)
+	out = append(out, make([]byte, len(blocks))...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out[aes.BlockSize:], blocks)
+	return out
+}
+
+func decode(t testing.TB, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+type decryptSeeds struct {
+	gcm, pbkdf2, argon2id, legacy, salted []byte
+}
+
+func newDecryptSeeds(t testing.TB) decryptSeeds {
+	gcm, err := StringEncrypt("hello", string(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbkdf2, err := StringEncryptWithKDF("hello", string(testKey), testPBKDF2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2id, err := StringEncryptWithKDF("hello", string(testKey), testArgon2id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decryptSeeds{
+		gcm:      decode(t, gcm),
+		pbkdf2:   decode(t, pbkdf2),
+		argon2id: decode(t, argon2id),
+		legacy:   legacyEncrypt(t, testKey, nil, pkcs7([]byte("hello legacy, in two blocks"))),
+		salted:   legacyEncrypt(t, testKey, testIV, pkcs7([]byte("hello salted, in two blocks"))),
+	}
+}
+
+func TestStringDecrypt(t *testing.T) {
+	seeds := newDecryptSeeds(t)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"gcm", seeds.gcm, "hello"},
+		{"pbkdf2", seeds.pbkdf2, "hello"},
+		{"argon2id", seeds.argon2id, "hello"},
+		{"legacy", seeds.legacy, "hello legacy, in two blocks"},
+		{"salted", seeds.salted, "hello salted, in two blocks"},
+	}
+	for _, tt := range tests {
+		got, err := StringDecrypt(base64.StdEncoding.EncodeToString(tt.data), string(testKey))
+		if err != nil || got != tt.want {
+			t.Errorf("%s: StringDecrypt = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	seeds := newDecryptSeeds(t)
+	key := string(testKey)
+	stringDecrypt := func(data []byte, key string) error {
+		_, err := StringDecrypt(base64.StdEncoding.EncodeToString(data), key)
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"gcm wrong key", stringDecrypt(seeds.gcm, string(testWrongKey)), ErrAuthFailed},
+		{"gcm altered", stringDecrypt(append(append([]byte(nil), seeds.gcm[:len(seeds.gcm)-1]...), seeds.gcm[len(seeds.gcm)-1]^1), key), ErrAuthFailed},
+		{"gcm cut in the tag", stringDecrypt(seeds.gcm[:len(seeds.gcm)-1], key), ErrAuthFailed},
+		{"gcm cut in the header", stringDecrypt(seeds.gcm[:10], key), ErrCiphertextTooShort},
+		{"gcm shorter than its overhead", func() error { _, err := Decrypt(seeds.gcm[:gcmOverhead-1], testKey); return err }(), ErrCiphertextTooShort},
+		{"pbkdf2 wrong key", stringDecrypt(seeds.pbkdf2, string(testWrongKey)), ErrAuthFailed},
+		{"argon2id wrong key", stringDecrypt(seeds.argon2id, string(testWrongKey)), ErrAuthFailed},
+		{"kdf cut in the salt", func() error { _, err := DecryptWithPassphrase(seeds.pbkdf2[:10], testKey); return err }(), ErrCiphertextTooShort},
+		{"legacy wrong key", stringDecrypt(seeds.legacy, string(testWrongKey)), ErrInvalidPadding},
+		{"legacy cut in a block", stringDecrypt(seeds.legacy[:aes.BlockSize-1], key), ErrCiphertextTooShort},
+		{"legacy not whole blocks", stringDecrypt(seeds.legacy[:aes.BlockSize+3], key), ErrInvalidPadding},
+		{"salted wrong key", stringDecrypt(seeds.salted, string(testWrongKey)), ErrInvalidPadding},
+		{"salted without blocks", func() error { _, err := aesDecryptWithSalt(testKey, seeds.salted[:aes.BlockSize]); return err }(), ErrCiphertextTooShort},
+		{"salted not whole blocks", func() error { _, err := aesDecryptWithSalt(testKey, seeds.salted[:len(seeds.salted)-1]); return err }(), ErrInvalidPadding},
+		{"zero padding", stringDecrypt(legacyEncrypt(t, testKey, nil, make([]byte, aes.BlockSize)), key), ErrInvalidPadding},
+		{"padding past the block", stringDecrypt(legacyEncrypt(t, testKey, nil, bytes.Repeat([]byte{17}, aes.BlockSize)), key), ErrInvalidPadding},
+		{"inconsistent padding", stringDecrypt(legacyEncrypt(t, testKey, testIV, append(bytes.Repeat([]byte{'a'}, 12), 3, 4, 4, 4)), key), ErrInvalidPadding},
+		{"empty", stringDecrypt(nil, key), ErrCiphertextTooShort},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+// cheapKDF tells the kdf params the fuzzer may run, the real bounds allow
+// a second of cpu and a GiB of memory per input.
+func cheapKDF(k KDF) bool {
+	if k.Algorithm == KDFArgon2id {
+		return k.Time <= 2 && k.Memory <= 1024
+	}
+	return k.Iterations <= 10000
+}
+
+func FuzzStringDecrypt(f *testing.F) {
+	seeds := newDecryptSeeds(f)
+	for _, data := range [][]byte{seeds.gcm, seeds.pbkdf2, seeds.argon2id, seeds.legacy, seeds.salted} {
+		f.Add(data)
+		f.Add(data[:len(data)-1])
+		f.Add(data[:len(data)/2])
+		f.Add(data[:aes.BlockSize])
+	}
+	f.Add([]byte{})
+	f.Add([]byte{versionGCM})
+	f.Add([]byte{versionKDF, byte(KDFPBKDF2)})
+	f.Add(legacyEncrypt(f, testKey, nil, make([]byte, aes.BlockSize)))
+	f.Add(legacyEncrypt(f, testKey, testIV, bytes.Repeat([]byte{17}, 2*aes.BlockSize)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if k, _, err := parseKDFHeader(data); err == nil && !cheapKDF(k) {
+			t.Skip("expensive kdf params")
+		}
+		if len(data) < 2*aes.BlockSize && isSaltPass(data) {
+			t.Errorf("isSaltPass accepted %d bytes without a block after the salt", len(data))
+		}
+		text := base64.StdEncoding.EncodeToString(data)
+		for _, key := range [][]byte{testKey, testWrongKey} {
+			_, err := StringDecrypt(text, string(key))
+			if err != nil && !errors.Is(err, ErrAuthFailed) && !errors.Is(err, ErrCiphertextTooShort) && !errors.Is(err, ErrInvalidPadding) {
+				t.Errorf("StringDecrypt(%x) returned an untyped error: %v", data, err)
+			}
+		}
+	})
+}
+
+// refUnPadding is the plain PKCS#7 check unPadding must agree with.
+func refUnPadding(data []byte, blockSize int) ([]byte, bool) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, false
+	}
+	n := int(data[len(data)-1])
+	if n < 1 || n > blockSize {
+		return nil, false
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, false
+		}
+	}
+	return data[:len(data)-n], true
+}
+
+func FuzzUnPadding(f *testing.F) {
+	f.Add(pkcs7([]byte("hello")))
+	f.Add(pkcs7(make([]byte, aes.BlockSize)))
+	f.Add([]byte{})
+	f.Add(make([]byte, aes.BlockSize))
+	f.Add(bytes.Repeat([]byte{17}, aes.BlockSize))
+	f.Add(bytes.Repeat([]byte{16}, aes.BlockSize-1))
+	f.Add(append(bytes.Repeat([]byte{'a'}, 12), 3, 4, 4, 4))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		got, err := unPadding(data, aes.BlockSize)
+		want, ok := refUnPadding(data, aes.BlockSize)
+		if !ok {
+			if !errors.Is(err, ErrInvalidPadding) {
+				t.Errorf("unPadding(%x) = %x, %v, want ErrInvalidPadding", data, got, err)
+			}
+			return
+		}
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("unPadding(%x) = %x, %v, want %x", data, got, err, want)
+		}
+	})
+}
+
+func TestEncryptKeySize(t *testing.T) {
+	for _, n := range []int{0, 1, 15, 17, 31, 33, 64} {
+		if _, err := Encrypt([]byte("hello"), make([]byte, n)); !errors.Is(err, ErrKeySize) {
+			t.Errorf("Encrypt with a %d bytes key: got %v, want ErrKeySize", n, err)
+		}
+		if _, err := StringEncrypt("hello", string(make([]byte, n))); !errors.Is(err, ErrKeySize) {
+			t.Errorf("StringEncrypt with a %d bytes key: got %v, want ErrKeySize", n, err)
+		}
+	}
+	for _, n := range []int{16, 24, 32} {
+		key := bytes.Repeat([]byte{'k'}, n)
+		text, err := StringEncrypt("hello", string(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := StringDecrypt(text, string(key)); err != nil || got != "hello" {
+			t.Errorf("%d bytes key: StringDecrypt = %q, %v, want hello", n, got, err)
+		}
+	}
+}
+
+// TestKeyRingDecryptString checks a ring blob of whole blocks, which could be
+// a legacy one, reports its retired or removed key instead of trying CBC.
+func TestKeyRingDecryptString(t *testing.T) {
+	old, err := GenerateKey("a", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := GenerateKey("b", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := NewKeyRing(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 + len("a") + 12 + len("x") + 16 bytes, two blocks
+	text, err := ring.EncryptString("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ring.DecryptString(text); err != nil || got != "x" {
+		t.Fatalf("DecryptString = %q, %v, want x", got, err)
+	}
+	if err := ring.Add(primary); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Retire("a", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.DecryptString(text); !errors.Is(err, ErrKeyRetired) {
+		t.Errorf("retired key: got %v, want ErrKeyRetired", err)
+	}
+	ring.Remove("a")
+	if _, err := ring.DecryptString(text); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("removed key: got %v, want ErrUnknownKey", err)
+	}
+}
@@ -0,0 +1,110 @@
+package MiaCrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// versionGCM is the first byte of the AES-256-GCM format:
+// version | nonce (12 bytes) | ciphertext | tag (16 bytes).
+// The legacy AES-CBC blobs have no version.
+const versionGCM byte = 2
+
+const gcmOverhead = 1 + 12 + 16
+
+// gcmKey hashes key to 32 bytes so the keys of the legacy format keep working with AES-256.
+func gcmKey(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:]
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	return newAEAD(gcmKey(key))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals plaintext with AES-256-GCM in the versioned format.
+// key must be 16, 24 or 32 bytes like the keys of the legacy format,
+// Decrypt still accepts any key for the blobs already written.
+func Encrypt(plaintext, key []byte) ([]byte, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrKeySize
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 1+aead.NonceSize(), gcmOverhead+len(plaintext))
+	out[0] = versionGCM
+	if _, err := io.ReadFull(rand.Reader, out[1:]); err != nil {
+		return nil, err
+	}
+	// the version is authenticated with the ciphertext
+	return aead.Seal(out, out[1:], plaintext, out[:1]), nil
+}
+
+// Decrypt opens a blob of Encrypt.
+func Decrypt(ciphertext, key []byte) ([]byte, error) {
+	if len(ciphertext) < gcmOverhead {
+		return nil, ErrCiphertextTooShort
+	}
+	if ciphertext[0] != versionGCM {
+		return nil, errors.New("MiaCrypt: not an AES-GCM ciphertext")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := ciphertext[1 : 1+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[1+aead.NonceSize():], ciphertext[:1])
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plaintext, nil
+}
+
+// isGCM reports whether data may be in the versioned format. A legacy blob is
+// a multiple of the AES block size, so it can only be mistaken for one when
+// its first byte is the version, StringDecrypt then falls back to CBC.
+func isGCM(data []byte) (maybe, certain bool) {
+	if len(data) < gcmOverhead || data[0] != versionGCM {
+		return false, false
+	}
+	return true, len(data)%aes.BlockSize != 0
+}
+
+// IsLegacy reports whether text, a result of StringEncrypt, is in the legacy
+// AES-CBC format and should be upgraded with ReEncrypt.
+func IsLegacy(text string) bool {
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return false
+	}
+	gcm, _ := isGCM(data)
+	kdf, _ := isKDF(data)
+	return !gcm && !kdf
+}
+
+// ReEncrypt decrypts text, in any format StringDecrypt reads, and encrypts it
+// again with the current format.
+func ReEncrypt(text string, key string) (string, error) {
+	plain, err := StringDecrypt(text, key)
+	if err != nil {
+		return "", err
+	}
+	return StringEncrypt(plain, key)
+}
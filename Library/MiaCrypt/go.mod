@@ -1,3 +1,7 @@
 module MiaGame/Library/MiaCrypt
 
 go 1.17
+
+require golang.org/x/crypto v0.22.0
+
+require golang.org/x/sys v0.19.0 // indirect
@@ -0,0 +1,175 @@
+package MiaCrypt
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// HashAlgorithm names a digest, the names are case insensitive.
+type HashAlgorithm string
+
+const (
+	MD5        HashAlgorithm = "md5" // only for compatibility, don't rely on it for integrity
+	SHA1       HashAlgorithm = "sha1"
+	SHA256     HashAlgorithm = "sha256"
+	SHA512     HashAlgorithm = "sha512"
+	SHA3_256   HashAlgorithm = "sha3-256"
+	SHA3_512   HashAlgorithm = "sha3-512"
+	BLAKE2b256 HashAlgorithm = "blake2b-256"
+	BLAKE2b512 HashAlgorithm = "blake2b-512"
+)
+
+func hashFunc(alg HashAlgorithm) (func() hash.Hash, error) {
+	switch HashAlgorithm(strings.ToLower(string(alg))) {
+	case MD5:
+		return md5.New, nil
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	case SHA3_256:
+		return sha3.New256, nil
+	case SHA3_512:
+		return sha3.New512, nil
+	case BLAKE2b256:
+		return func() hash.Hash { h, _ := blake2b.New256(nil); return h }, nil
+	case BLAKE2b512:
+		return func() hash.Hash { h, _ := blake2b.New512(nil); return h }, nil
+	}
+	return nil, fmt.Errorf("MiaCrypt: unknown hash algorithm %q", string(alg))
+}
+
+// NewHash returns a hash.Hash computing alg.
+func NewHash(alg HashAlgorithm) (hash.Hash, error) {
+	f, err := hashFunc(alg)
+	if err != nil {
+		return nil, err
+	}
+	return f(), nil
+}
+
+// Sum returns the alg digest of data.
+func Sum(alg HashAlgorithm, data []byte) ([]byte, error) {
+	h, err := NewHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// SumString returns the alg digest of s in hex, like GetMd5String.
+func SumString(alg HashAlgorithm, s string) (string, error) {
+	sum, err := Sum(alg, []byte(s))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// NewHMAC returns a hash.Hash computing the HMAC of alg with key.
+func NewHMAC(alg HashAlgorithm, key []byte) (hash.Hash, error) {
+	f, err := hashFunc(alg)
+	if err != nil {
+		return nil, err
+	}
+	return hmac.New(f, key), nil
+}
+
+// HMAC returns the HMAC of data.
+func HMAC(alg HashAlgorithm, key, data []byte) ([]byte, error) {
+	h, err := NewHMAC(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// HMACString returns the HMAC of s in hex.
+func HMACString(alg HashAlgorithm, key, s string) (string, error) {
+	mac, err := HMAC(alg, []byte(key), []byte(s))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac), nil
+}
+
+// VerifyHMAC checks mac against the HMAC of data in constant time.
+func VerifyHMAC(alg HashAlgorithm, key, data, mac []byte) bool {
+	expected, err := HMAC(alg, key, data)
+	return err == nil && hmac.Equal(mac, expected)
+}
+
+// VerifyHMACString is VerifyHMAC for a mac of HMACString, the hex case is ignored.
+func VerifyHMACString(alg HashAlgorithm, key, s, mac string) bool {
+	b, err := hex.DecodeString(mac)
+	return err == nil && VerifyHMAC(alg, []byte(key), []byte(s), b)
+}
+
+// ProgressFunc is called while a stream is hashed with the bytes done so far,
+// total is -1 when the size is unknown.
+type ProgressFunc func(done, total int64)
+
+type progressWriter struct {
+	done, total int64
+	progress    ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	w.progress(w.done, w.total)
+	return len(p), nil
+}
+
+// SumReader hashes r until EOF, progress may be nil.
+func SumReader(alg HashAlgorithm, r io.Reader, progress ProgressFunc) ([]byte, error) {
+	return sumReader(alg, r, -1, progress)
+}
+
+func sumReader(alg HashAlgorithm, r io.Reader, total int64, progress ProgressFunc) ([]byte, error) {
+	h, err := NewHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = h
+	if progress != nil {
+		w = io.MultiWriter(h, &progressWriter{total: total, progress: progress})
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// SumFile returns the alg digest of the file in hex, progress may be nil.
+func SumFile(alg HashAlgorithm, name string, progress ProgressFunc) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	total := int64(-1)
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		total = fi.Size()
+	}
+	sum, err := sumReader(alg, f, total, progress)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
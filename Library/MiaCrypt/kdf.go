@@ -0,0 +1,202 @@
+package MiaCrypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// versionKDF is the first byte of the passphrase format:
+// version | kdf | kdf params | salt (16 bytes) | nonce (12 bytes) | ciphertext | tag (16 bytes).
+// The header before the nonce is authenticated with the ciphertext.
+const versionKDF byte = 3
+
+const kdfSaltSize = 16
+
+// KDFAlgorithm identifies a key derivation function in the passphrase format.
+type KDFAlgorithm byte
+
+const (
+	// KDFArgon2id params: Time, Memory (KiB) and Threads.
+	KDFArgon2id KDFAlgorithm = 1
+	// KDFPBKDF2 is PBKDF2-HMAC-SHA256, params: Iterations.
+	KDFPBKDF2 KDFAlgorithm = 2
+)
+
+// KDF derives 32-byte keys from passphrases.
+type KDF struct {
+	Algorithm  KDFAlgorithm
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	Iterations uint32
+}
+
+var (
+	// DefaultArgon2id follows the second recommended option of RFC 9106.
+	DefaultArgon2id = KDF{Algorithm: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	// DefaultPBKDF2 is for platforms where Argon2id is too expensive.
+	DefaultPBKDF2 = KDF{Algorithm: KDFPBKDF2, Iterations: 600000}
+)
+
+// the upper bounds stop a crafted header from exhausting memory or cpu
+const (
+	maxArgon2Time       = 16
+	maxArgon2Memory     = 1024 * 1024
+	maxPBKDF2Iterations = 10000000
+)
+
+func (k KDF) paramsSize() int {
+	switch k.Algorithm {
+	case KDFArgon2id:
+		return 9
+	case KDFPBKDF2:
+		return 4
+	}
+	return 0
+}
+
+func (k KDF) validate() error {
+	switch k.Algorithm {
+	case KDFArgon2id:
+		if k.Time < 1 || k.Time > maxArgon2Time || k.Threads < 1 ||
+			k.Memory < 8*uint32(k.Threads) || k.Memory > maxArgon2Memory {
+			return fmt.Errorf("MiaCrypt: invalid argon2id params t=%d m=%d p=%d", k.Time, k.Memory, k.Threads)
+		}
+	case KDFPBKDF2:
+		if k.Iterations < 1 || k.Iterations > maxPBKDF2Iterations {
+			return fmt.Errorf("MiaCrypt: invalid pbkdf2 iterations %d", k.Iterations)
+		}
+	default:
+		return fmt.Errorf("MiaCrypt: unknown kdf %d", k.Algorithm)
+	}
+	return nil
+}
+
+// DeriveKey derives a 32-byte key from passphrase and salt.
+func (k KDF) DeriveKey(passphrase, salt []byte) ([]byte, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	if k.Algorithm == KDFArgon2id {
+		return argon2.IDKey(passphrase, salt, k.Time, k.Memory, k.Threads, 32), nil
+	}
+	return pbkdf2.Key(passphrase, salt, int(k.Iterations), 32, sha256.New), nil
+}
+
+func (k KDF) appendHeader(dst []byte) []byte {
+	p := make([]byte, k.paramsSize())
+	switch k.Algorithm {
+	case KDFArgon2id:
+		binary.BigEndian.PutUint32(p, k.Time)
+		binary.BigEndian.PutUint32(p[4:], k.Memory)
+		p[8] = k.Threads
+	case KDFPBKDF2:
+		binary.BigEndian.PutUint32(p, k.Iterations)
+	}
+	return append(append(dst, versionKDF, byte(k.Algorithm)), p...)
+}
+
+// parseKDFHeader returns the kdf of data and the size of the header, salt included.
+func parseKDFHeader(data []byte) (KDF, int, error) {
+	if len(data) < 2 || data[0] != versionKDF {
+		return KDF{}, 0, errors.New("MiaCrypt: not a passphrase ciphertext")
+	}
+	k := KDF{Algorithm: KDFAlgorithm(data[1])}
+	n := 2 + k.paramsSize()
+	if len(data) < n+kdfSaltSize+gcmOverhead-1 {
+		return KDF{}, 0, ErrCiphertextTooShort
+	}
+	p := data[2:n]
+	switch k.Algorithm {
+	case KDFArgon2id:
+		k.Time = binary.BigEndian.Uint32(p)
+		k.Memory = binary.BigEndian.Uint32(p[4:])
+		k.Threads = p[8]
+	case KDFPBKDF2:
+		k.Iterations = binary.BigEndian.Uint32(p)
+	}
+	return k, n + kdfSaltSize, k.validate()
+}
+
+// EncryptWithPassphrase seals plaintext with AES-256-GCM under a key derived
+// from passphrase, the salt and the kdf params are stored in the header.
+func EncryptWithPassphrase(plaintext, passphrase []byte, kdf KDF) ([]byte, error) {
+	if err := kdf.validate(); err != nil {
+		return nil, err
+	}
+	header := kdf.appendHeader(make([]byte, 0, 2+kdf.paramsSize()+kdfSaltSize))
+	salt := header[len(header) : len(header)+kdfSaltSize]
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	header = header[:len(header)+kdfSaltSize]
+	key, err := kdf.DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// DecryptWithPassphrase opens a blob of EncryptWithPassphrase.
+func DecryptWithPassphrase(ciphertext, passphrase []byte) ([]byte, error) {
+	kdf, n, err := parseKDFHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kdf.DeriveKey(passphrase, ciphertext[n-kdfSaltSize:n])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := ciphertext[n : n+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[n+aead.NonceSize():], ciphertext[:n])
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plaintext, nil
+}
+
+// isKDF is isGCM for the passphrase format.
+func isKDF(data []byte) (maybe, certain bool) {
+	if _, _, err := parseKDFHeader(data); err != nil {
+		return false, false
+	}
+	return true, len(data)%16 != 0
+}
+
+// StringEncryptPassphrase is StringEncrypt with a key derived from passphrase by
+// DefaultArgon2id, so passphrases and env secrets of any length are as strong as they can be.
+// StringDecrypt reads it with the same passphrase.
+func StringEncryptPassphrase(text string, passphrase string) (string, error) {
+	return StringEncryptWithKDF(text, passphrase, DefaultArgon2id)
+}
+
+// StringEncryptWithKDF is StringEncryptPassphrase with another kdf.
+func StringEncryptWithKDF(text string, passphrase string, kdf KDF) (string, error) {
+	xpass, err := EncryptWithPassphrase([]byte(text), []byte(passphrase), kdf)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(xpass), nil
+}
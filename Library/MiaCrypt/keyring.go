@@ -0,0 +1,359 @@
+package MiaCrypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// versionKeyRing is the first byte of the key ring format:
+// version | kid length | kid | nonce (12 bytes) | ciphertext | tag (16 bytes).
+// The header before the nonce is authenticated with the ciphertext.
+const versionKeyRing byte = 4
+
+var (
+	// ErrNoActiveKey is returned when no key of the ring is active to encrypt.
+	ErrNoActiveKey = errors.New("MiaCrypt: no active key in the key ring")
+	// ErrUnknownKey is returned when the ciphertext names a key the ring doesn't hold.
+	ErrUnknownKey = errors.New("MiaCrypt: unknown key id")
+	// ErrKeyRetired is returned when the ciphertext was encrypted with a retired key.
+	ErrKeyRetired = errors.New("MiaCrypt: key is retired")
+)
+
+// Key is a key of a KeyRing. It encrypts from Activate and decrypts until
+// Retire, a zero time means no limit.
+type Key struct {
+	ID       string    `json:"id"`
+	Secret   []byte    `json:"secret"` // base64 in json
+	Activate time.Time `json:"activate,omitempty"`
+	Retire   time.Time `json:"retire,omitempty"`
+}
+
+func (k Key) retired(now time.Time) bool {
+	return !k.Retire.IsZero() && !now.Before(k.Retire)
+}
+
+func (k Key) active(now time.Time) bool {
+	return !k.retired(now) && !now.Before(k.Activate)
+}
+
+func validKeyID(id string) bool {
+	if id == "" || len(id) > 255 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateKey returns a random 32-byte key activated at activate.
+func GenerateKey(id string, activate time.Time) (Key, error) {
+	k := Key{ID: id, Secret: make([]byte, 32), Activate: activate}
+	_, err := io.ReadFull(rand.Reader, k.Secret)
+	return k, err
+}
+
+// KeyRing holds keys by id. New ciphertexts are encrypted with the primary
+// key, the active key activated last, and embed its id so Decrypt picks the
+// right key. Keys are rotated by adding one with a later Activate, possibly in
+// the future, and retired once their ciphertexts were re-encrypted.
+// A KeyRing is safe for concurrent use.
+type KeyRing struct {
+	mutex sync.RWMutex
+	keys  map[string]Key
+}
+
+// NewKeyRing returns a ring holding keys.
+func NewKeyRing(keys ...Key) (*KeyRing, error) {
+	r := &KeyRing{keys: make(map[string]Key)}
+	for _, k := range keys {
+		if err := r.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add adds k or replaces the key with the same id. Ids are made of letters,
+// digits, '-' and '_'.
+func (r *KeyRing) Add(k Key) error {
+	if !validKeyID(k.ID) {
+		return fmt.Errorf("MiaCrypt: invalid key id %q", k.ID)
+	}
+	if len(k.Secret) < 16 {
+		return fmt.Errorf("MiaCrypt: key %s is shorter than 16 bytes", k.ID)
+	}
+	k.Secret = append([]byte(nil), k.Secret...)
+	r.mutex.Lock()
+	r.keys[k.ID] = k
+	r.mutex.Unlock()
+	return nil
+}
+
+// Retire stops the key id from decrypting at t.
+func (r *KeyRing) Retire(id string, t time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	k, ok := r.keys[id]
+	if !ok {
+		return ErrUnknownKey
+	}
+	k.Retire = t
+	r.keys[id] = k
+	return nil
+}
+
+// Remove drops the key id.
+func (r *KeyRing) Remove(id string) {
+	r.mutex.Lock()
+	delete(r.keys, id)
+	r.mutex.Unlock()
+}
+
+// Keys returns the keys ordered by Activate.
+func (r *KeyRing) Keys() []Key {
+	r.mutex.RLock()
+	keys := make([]Key, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	r.mutex.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Activate.Equal(keys[j].Activate) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].Activate.Before(keys[j].Activate)
+	})
+	return keys
+}
+
+// Primary returns the key encrypting now.
+func (r *KeyRing) Primary() (Key, error) {
+	now := time.Now()
+	keys := r.Keys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].active(now) {
+			return keys[i], nil
+		}
+	}
+	return Key{}, ErrNoActiveKey
+}
+
+// Key returns the key id if it can decrypt now.
+func (r *KeyRing) Key(id string) (Key, error) {
+	r.mutex.RLock()
+	k, ok := r.keys[id]
+	r.mutex.RUnlock()
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+	if k.retired(time.Now()) {
+		return Key{}, ErrKeyRetired
+	}
+	return k, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM under the primary key.
+func (r *KeyRing) Encrypt(plaintext []byte) ([]byte, error) {
+	k, err := r.Primary()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(k.Secret)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{versionKeyRing, byte(len(k.ID))}, k.ID...)
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// KeyID returns the id of the key a ciphertext of Encrypt was sealed with.
+func KeyID(ciphertext []byte) (string, error) {
+	if len(ciphertext) < 2 || ciphertext[0] != versionKeyRing {
+		return "", errors.New("MiaCrypt: not a key ring ciphertext")
+	}
+	n := 2 + int(ciphertext[1])
+	if len(ciphertext) < n+gcmOverhead-1 {
+		return "", ErrCiphertextTooShort
+	}
+	return string(ciphertext[2:n]), nil
+}
+
+// Decrypt opens a blob of Encrypt with the key it names.
+func (r *KeyRing) Decrypt(ciphertext []byte) ([]byte, error) {
+	id, err := KeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	k, err := r.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(k.Secret)
+	if err != nil {
+		return nil, err
+	}
+	n := 2 + len(id)
+	nonce := ciphertext[n : n+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[n+aead.NonceSize():], ciphertext[:n])
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plaintext, nil
+}
+
+// EncryptString is Encrypt encoded in base64, for configs, columns and cookies.
+func (r *KeyRing) EncryptString(text string) (string, error) {
+	b, err := r.Encrypt([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecryptString decrypts EncryptString. Ciphertexts of StringEncrypt made with
+// the secret of a key of the ring are accepted too, so single keys can be
+// moved into a ring.
+func (r *KeyRing) DecryptString(text string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", err
+	}
+	if _, err := KeyID(b); err == nil {
+		plain, err := r.Decrypt(b)
+		// a legacy blob is a multiple of the block size, see isGCM, and only
+		// tried when the key named by b doesn't authenticate it: a retired or
+		// unknown key is reported as is
+		if !errors.Is(err, ErrAuthFailed) || len(b)%16 != 0 {
+			return string(plain), err
+		}
+	}
+	now := time.Now()
+	keys := r.Keys()
+	err = ErrUnknownKey
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].retired(now) {
+			continue
+		}
+		var plain string
+		if plain, err = StringDecrypt(text, string(keys[i].Secret)); err == nil {
+			return plain, nil
+		}
+	}
+	return "", err
+}
+
+// NeedsRotation reports whether text, a result of EncryptString, wasn't
+// encrypted with the primary key and should be re-encrypted.
+func (r *KeyRing) NeedsRotation(text string) bool {
+	b, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return false
+	}
+	id, err := KeyID(b)
+	if err != nil {
+		return true
+	}
+	k, err := r.Primary()
+	return err == nil && k.ID != id
+}
+
+// ReEncrypt decrypts text and encrypts it again with the primary key.
+func (r *KeyRing) ReEncrypt(text string) (string, error) {
+	plain, err := r.DecryptString(text)
+	if err != nil {
+		return "", err
+	}
+	return r.EncryptString(plain)
+}
+
+// Sign returns the HMAC-SHA256 of msg by the primary key and the key id.
+func (r *KeyRing) Sign(msg []byte) (string, []byte, error) {
+	k, err := r.Primary()
+	if err != nil {
+		return "", nil, err
+	}
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write(msg)
+	return k.ID, mac.Sum(nil), nil
+}
+
+// Verify checks sum, a result of Sign by the key id, in constant time.
+func (r *KeyRing) Verify(id string, msg, sum []byte) bool {
+	k, err := r.Key(id)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write(msg)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+type keyRingFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// MarshalJSON encodes the ring as {"keys": [...]}, the format of LoadKeyRingFile.
+func (r *KeyRing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(keyRingFile{Keys: r.Keys()})
+}
+
+// ParseKeyRing decodes a ring from json:
+//
+//	{"keys": [{"id": "2024-01", "secret": "<base64>", "activate": "2024-01-01T00:00:00Z", "retire": "..."}]}
+func ParseKeyRing(data []byte) (*KeyRing, error) {
+	var f keyRingFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("MiaCrypt: parse key ring: %w", err)
+	}
+	return NewKeyRing(f.Keys...)
+}
+
+// LoadKeyRingFile reads a ring written by SaveKeyRingFile.
+func LoadKeyRingFile(file string) (*KeyRing, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyRing(data)
+}
+
+// LoadKeyRingEnv reads a ring from the json in the env variable name, or from
+// the file named by name + "_FILE".
+func LoadKeyRingEnv(name string) (*KeyRing, error) {
+	if data := os.Getenv(name); data != "" {
+		return ParseKeyRing([]byte(data))
+	}
+	if file := os.Getenv(name + "_FILE"); file != "" {
+		return LoadKeyRingFile(file)
+	}
+	return nil, fmt.Errorf("MiaCrypt: neither %s nor %s_FILE is set", name, name)
+}
+
+// SaveKeyRingFile writes r to file, readable by the owner only.
+func SaveKeyRingFile(file string, r *KeyRing) error {
+	data, err := json.MarshalIndent(keyRingFile{Keys: r.Keys()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0600)
+}
@@ -0,0 +1,131 @@
+package MiaCrypt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Alphabets of RandomString.
+const (
+	AlphabetDigits       = "0123456789"
+	AlphabetLower        = "abcdefghijklmnopqrstuvwxyz"
+	AlphabetUpper        = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	AlphabetAlphanumeric = AlphabetDigits + AlphabetUpper + AlphabetLower
+	AlphabetHex          = "0123456789abcdef"
+	// AlphabetReadable leaves out 0, O, 1, I and l, for codes typed by players.
+	AlphabetReadable = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// RandomBytes returns n bytes from crypto/rand.
+func RandomBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("MiaCrypt: RandomBytes length is negative")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RandomString returns n characters drawn uniformly from alphabet.
+func RandomString(n int, alphabet string) (string, error) {
+	chars := []rune(alphabet)
+	if len(chars) == 0 || len(chars) > 256 {
+		return "", errors.New("MiaCrypt: the alphabet must hold 1 to 256 characters")
+	}
+	if n < 0 {
+		return "", errors.New("MiaCrypt: RandomString length is negative")
+	}
+	// bytes above the largest multiple of the alphabet size are rejected,
+	// taking them modulo would favour the first characters
+	limit := 256 - 256%len(chars)
+	out := make([]rune, 0, n)
+	buf := make([]byte, n+n/4+8)
+	for len(out) < n {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			out = append(out, chars[int(b)%len(chars)])
+			if len(out) == n {
+				break
+			}
+		}
+	}
+	return string(out), nil
+}
+
+// RandomInt returns a uniform random int in [0, max).
+func RandomInt(max int64) (int64, error) {
+	if max <= 0 {
+		return 0, errors.New("MiaCrypt: RandomInt max must be positive")
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(max))
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64(), nil
+}
+
+// RandomRange returns a uniform random int in [min, max].
+func RandomRange(min, max int64) (int64, error) {
+	if max < min {
+		return 0, errors.New("MiaCrypt: RandomRange max is less than min")
+	}
+	span := new(big.Int).Sub(big.NewInt(max), big.NewInt(min))
+	span.Add(span, big.NewInt(1))
+	n, err := rand.Int(rand.Reader, span)
+	if err != nil {
+		return 0, err
+	}
+	return n.Add(n, big.NewInt(min)).Int64(), nil
+}
+
+// UUIDv4 returns a random UUID of RFC 9562.
+func UUIDv4() (string, error) {
+	b, err := RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+// UUIDv7 returns a UUID of RFC 9562 starting with the unix time in
+// milliseconds, so they sort by creation time, e.g. as database keys.
+func UUIDv7() (string, error) {
+	b, err := RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(b[:6], ms[2:])
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+func formatUUID(b []byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf, b[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
@@ -0,0 +1,166 @@
+package MiaCrypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// versionStream is the first byte of the stream format:
+// version | chunk size (uint32) | salt (32 bytes) | chunks.
+// Every chunk is sealed with AES-256-GCM under a key derived from the key and
+// the salt, the nonce is the chunk counter and a flag set on the last chunk,
+// so reordered, dropped or truncated chunks are detected. The header is
+// authenticated with every chunk.
+const versionStream byte = 5
+
+const (
+	streamHeaderSize = 1 + 4 + 32
+	// DefaultChunkSize of EncryptStream.
+	DefaultChunkSize = 64 * 1024
+	maxChunkSize     = 16 * 1024 * 1024
+)
+
+// ErrTruncated is returned by DecryptStream when the stream ends before its last chunk.
+var ErrTruncated = errors.New("MiaCrypt: encrypted stream is truncated")
+
+func streamAEAD(key, header []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, gcmKey(key))
+	mac.Write(header[5:])
+	return newAEAD(mac.Sum(nil))
+}
+
+func streamNonce(nonce []byte, counter uint64, last bool) {
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	nonce[11] = 0
+	if last {
+		nonce[11] = 1
+	}
+}
+
+// EncryptStream encrypts src to dst in chunks of DefaultChunkSize until EOF.
+func EncryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	return EncryptStreamChunks(dst, src, key, DefaultChunkSize)
+}
+
+// EncryptStreamChunks is EncryptStream with another chunk size, up to 16 MiB.
+func EncryptStreamChunks(dst io.Writer, src io.Reader, key []byte, chunkSize int) error {
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return fmt.Errorf("MiaCrypt: invalid chunk size %d", chunkSize)
+	}
+	header := make([]byte, streamHeaderSize)
+	header[0] = versionStream
+	binary.BigEndian.PutUint32(header[1:5], uint32(chunkSize))
+	if _, err := io.ReadFull(rand.Reader, header[5:]); err != nil {
+		return err
+	}
+	aead, err := streamAEAD(key, header)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 12)
+	buf := make([]byte, chunkSize, chunkSize+aead.Overhead())
+	// a chunk is the last one when the next read gets nothing
+	next := make([]byte, chunkSize, chunkSize+aead.Overhead())
+	n, err := io.ReadFull(src, buf)
+	for counter := uint64(0); ; counter++ {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		var m int
+		if !last {
+			m, err = io.ReadFull(src, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			last = m == 0
+		}
+		streamNonce(nonce, counter, last)
+		if _, err := dst.Write(aead.Seal(buf[:0], nonce, buf[:n], header)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		buf, next = next, buf[:chunkSize]
+		n = m
+	}
+}
+
+// DecryptStream decrypts a stream of EncryptStream from src to dst. The
+// plaintext is written chunk by chunk as it is authenticated, when an error
+// is returned what was written must be discarded: the stream may have been
+// truncated or altered after it.
+func DecryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCiphertextTooShort
+		}
+		return err
+	}
+	if header[0] != versionStream {
+		return errors.New("MiaCrypt: not an encrypted stream")
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[1:5]))
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return fmt.Errorf("MiaCrypt: invalid chunk size %d", chunkSize)
+	}
+	aead, err := streamAEAD(key, header)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(src)
+	nonce := make([]byte, 12)
+	buf := make([]byte, chunkSize+aead.Overhead())
+	// Open clears its output on failure, it can't decrypt in place
+	out := make([]byte, chunkSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return ErrTruncated
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err == io.ErrUnexpectedEOF
+		if !last {
+			if _, err := r.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+		streamNonce(nonce, counter, last)
+		plain, err := aead.Open(out[:0], nonce, buf[:n], header)
+		if err != nil {
+			if !last {
+				return ErrAuthFailed
+			}
+			// a full chunk followed by nothing is a cut stream unless it is flagged last
+			streamNonce(nonce, counter, false)
+			if _, err := aead.Open(out[:0], nonce, buf[:n], header); err == nil {
+				return ErrTruncated
+			}
+			return ErrAuthFailed
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
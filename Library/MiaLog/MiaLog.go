@@ -3,383 +3,441 @@ package MiaLog
 import (
     "io"
     "os"
-    "strings"
+    "path/filepath"
+    "sync"
+    "sync/atomic"
     "time"
 
-    rotatelogs "github.com/lestrrat-go/file-rotatelogs"
     "go.uber.org/zap"
     "go.uber.org/zap/zapcore"
 )
 
-var textLogger *zap.SugaredLogger
-var jsonLogger *zap.SugaredLogger
+// loggers is swapped as a whole by Init and Close, so the log functions can be
+// called from any goroutine before, during and after initialization.
+type loggers struct {
+    json     *zap.SugaredLogger
+    text     *zap.SugaredLogger
+    closers  []io.Closer
+    // modules holds the outputs of the Named loggers, the ones without dedicated
+    // files use textAll: the text outputs without their level, checked by moduleCore.
+    modules  map[string]zapcore.Core
+    textAll  zapcore.Core
+    fields   []zapcore.Field
+    crashDir string
+}
+
+var current atomic.Value // *loggers
+var closeMutex sync.Mutex
+
+var defaultRedactor, _ = newRedactor(DefaultRedactConfig())
+
+func init() {
+    current.Store(defaultLoggers())
+}
 
-func zapLogLevel(level string) zap.AtomicLevel {
+// defaultLoggers writes info and above to stderr, it is used until Init is called.
+func defaultLoggers() *loggers {
+    stderr := outputs{{encoder: defaultRedactor.wrap(getEncoder("console", false)), writer: zapcore.Lock(os.Stderr)}}
+    return &loggers{
+        json:    zap.New(stderr.core(jsonLevel), zap.AddCaller(), zap.AddCallerSkip(2)).Sugar(),
+        text:    zap.New(stderr.core(textLevel), zap.AddCaller(), zap.AddCallerSkip(1)).Sugar(),
+        textAll: stderr.core(zapcore.DebugLevel),
+    }
+}
+
+func load() *loggers {
+    return current.Load().(*loggers)
+}
+
+func zapLogLevel(level string) zapcore.Level {
 
     switch level {
     case "debug":
-        return zap.NewAtomicLevelAt(zap.DebugLevel)
+        return zap.DebugLevel
     case "info":
-        return zap.NewAtomicLevelAt(zap.InfoLevel)
+        return zap.InfoLevel
     case "warn":
-        return zap.NewAtomicLevelAt(zap.WarnLevel)
+        return zap.WarnLevel
     case "error":
-        return zap.NewAtomicLevelAt(zap.ErrorLevel)
+        return zap.ErrorLevel
     }
 
     // default is info level
-    return zap.NewAtomicLevelAt(zap.InfoLevel)
-}
-func InitLevelWithDay(level string,hourCount int){
-    cfg := zapcore.EncoderConfig{
-        MessageKey:  "msg",
-        LevelKey:    "level",
-        EncodeLevel: zapcore.CapitalColorLevelEncoder,
-        TimeKey:     "timestamp",
-        EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-            enc.AppendString(t.Format("2006-01-02 15:04:05"))
-        },
-        CallerKey:    "file",
-        EncodeCaller: zapcore.ShortCallerEncoder,
-        EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
-            enc.AppendInt64(int64(d) / 1000000)
-        },
+    return zap.InfoLevel
+}
+// InitLevelWithDay is InitLevel with the json files rotated every hourCount hours and kept 7 rotations.
+func InitLevelWithDay(level string, hourCount int) {
+    cfg := DefaultConfig(level)
+    rotation := cfg.Rotation
+    rotation.RotationTime = time.Hour * time.Duration(hourCount)
+    rotation.MaxAge = time.Hour * time.Duration(hourCount) * 7
+    for i := range cfg.Json.Sinks {
+        cfg.Json.Sinks[i].Rotation = &rotation
     }
+    if err := Init(cfg); err != nil {
+        os.Stderr.WriteString("MiaLog: init error: " + err.Error() + "\n")
+    }
+}
 
-    // 设置一些基本日志格式 具体含义还比较好理解 直接看zap源码也不难懂
-    textEncoder :=getEncoder();
-    //
-
-    // 实现两个判断日志等级的 interface
-    //debugLevle := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-    //    return lvl == zapcore.DebugLevel
-    //})
-    infoLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl == zapcore.InfoLevel
-    })
-    infoLevel=infoLevel
-    //warnLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-    //    return lvl == zapcore.WarnLevel
-    //})
-    errorLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl == zapcore.ErrorLevel
-    })
-    // 打印所有级别的日志
-    lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl >= zapLogLevel(level).Level()
-    })
-    consoleDebugging := zapcore.Lock(os.Stdout)
-    //jsonDebugWriter := getWriter("./logs/trade-debug.log")
-    jsonInfoWriter := getWriterByTime("./logs/trade-info.log",hourCount)
-    //jsonWarnWriter := getWriter("./logs/trade-warn.log")
-    jsonErrorWriter := getWriterByTime("./logs/trade-error.log",hourCount)
-    jsonEncoder := zapcore.NewJSONEncoder(cfg)
-    jsonCore := zapcore.NewTee(
-        zapcore.NewCore(jsonEncoder, zapcore.AddSync(jsonInfoWriter), infoLevel),
-        zapcore.NewCore(jsonEncoder,consoleDebugging,lowPriority),
-        zapcore.NewCore(jsonEncoder, zapcore.AddSync(jsonErrorWriter), errorLevel),
-    )
-
-    jsonLog := zap.New(jsonCore, zap.AddCaller(),zap.AddCallerSkip(2)) // 需要传入 zap.AddCaller() 才会显示打日志点的文件名和行数, 有点小坑
-    jsonLogger = jsonLog.Sugar()
-
-    ////获取 info、error日志文件的io.Writer 抽象 getWriter() 在下方实现
-    //textDebugWriter := getWriter("./logs/trade-text-debug.log")
-    textInfoWriter := getWriter("./logs/trade-text-info.log")
-    //textWarnWriter := getWriter("./logs/trade-text-warn.log")
-    textErrorWriter := getWriter("./logs/trade-text-error.log")
-
-    //最后创建具体的 Logger
-    textCore := zapcore.NewTee(
-        zapcore.NewCore(textEncoder, zapcore.AddSync(textInfoWriter), infoLevel),
-        zapcore.NewCore(textEncoder,consoleDebugging,lowPriority),
-        zapcore.NewCore(textEncoder, zapcore.AddSync(textErrorWriter), errorLevel),
-    )
-    //
-
-    testLog := zap.New(textCore, zap.AddCaller(),zap.AddCallerSkip(1)) // 需要传入 zap.AddCaller() 才会显示打日志点的文件名和行数, 有点小坑
-    textLogger = testLog.Sugar()
-
-}
-func InitLevel(level string ){
-    cfg := zapcore.EncoderConfig{
-       MessageKey:  "msg",
-       LevelKey:    "level",
-       EncodeLevel: zapcore.CapitalColorLevelEncoder,
-       TimeKey:     "timestamp",
-       EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-           enc.AppendString(t.Format("2006-01-02 15:04:05"))
-       },
-       CallerKey:    "file",
-       EncodeCaller: zapcore.ShortCallerEncoder,
-       EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
-           enc.AppendInt64(int64(d) / 1000000)
-       },
+// InitLevel initializes the loggers with DefaultConfig(level).
+func InitLevel(level string) {
+    if err := Init(DefaultConfig(level)); err != nil {
+        os.Stderr.WriteString("MiaLog: init error: " + err.Error() + "\n")
     }
+}
 
-    // 设置一些基本日志格式 具体含义还比较好理解 直接看zap源码也不难懂
-    textEncoder :=getEncoder();
-   //
+// Init builds the json and text loggers from cfg.
+func Init(cfg Config) error {
+    cfg.setDefaults()
+    redactCfg := DefaultRedactConfig()
+    if cfg.Redact != nil {
+        redactCfg = *cfg.Redact
+    }
+    r, err := newRedactor(redactCfg)
+    if err != nil {
+        return err
+    }
+    var closers []io.Closer
+    jsonOutputs, err := buildOutputs(cfg, cfg.Json, r, &closers)
+    if err != nil {
+        closeAll(closers)
+        return err
+    }
+    textOutputs, err := buildOutputs(cfg, cfg.Text, r, &closers)
+    if err != nil {
+        closeAll(closers)
+        return err
+    }
+    textAll := cfg.limit(textOutputs.core(zapcore.DebugLevel), &closers)
+    modules := make(map[string]zapcore.Core)
+    moduleLevels := make(map[string]zapcore.Level)
+    for name, mc := range cfg.Modules {
+        if mc.Level != "" {
+            moduleLevels[name] = zapLogLevel(mc.Level)
+        }
+        if len(mc.Sinks) == 0 && !mc.Exclusive {
+            continue
+        }
+        lc := LoggerConfig{Encoding: mc.Encoding, Sinks: mc.Sinks}
+        if lc.Encoding == "" {
+            lc.Encoding = cfg.Text.Encoding
+        }
+        dedicated, err := buildOutputs(cfg, lc, r, &closers)
+        if err != nil {
+            closeAll(closers)
+            return err
+        }
+        modules[name] = cfg.limit(dedicated.core(zapcore.DebugLevel), &closers)
+        if !mc.Exclusive {
+            modules[name] = zapcore.NewTee(modules[name], textAll)
+        }
+    }
 
-    // 实现两个判断日志等级的 interface
-    //debugLevle := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-    //    return lvl == zapcore.DebugLevel
-    //})
-    infoLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl == zapcore.InfoLevel
-    })
-    infoLevel=infoLevel
-    //warnLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-    //    return lvl == zapcore.WarnLevel
-    //})
-    errorLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl == zapcore.ErrorLevel
-    })
-    // 打印所有级别的日志
-    lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-        return lvl >= zapLogLevel(level).Level()
+    var fields []zapcore.Field
+    if cfg.Service != "" {
+        fields = append(fields, zap.String(ServiceKey, cfg.Service))
+    }
+    // 需要传入 zap.AddCaller() 才会显示打日志点的文件名和行数, 有点小坑
+    jsonCore := cfg.limit(jsonOutputs.core(jsonLevel), &closers)
+    textCore := cfg.limit(textOutputs.core(textLevel), &closers)
+    jsonLog := zap.New(jsonCore, zap.AddCaller(), zap.AddCallerSkip(2), zap.Fields(fields...))
+    testLog := zap.New(textCore, zap.AddCaller(), zap.AddCallerSkip(1), zap.Fields(fields...))
+    setInitLevels(zapLogLevel(cfg.Json.levelOr(cfg.Level)), zapLogLevel(cfg.Text.levelOr(cfg.Level)), moduleLevels)
+    swap(&loggers{
+        json:     jsonLog.Sugar(),
+        text:     testLog.Sugar(),
+        closers:  closers,
+        modules:  modules,
+        textAll:  textAll,
+        fields:   fields,
+        crashDir: cfg.CrashDir,
     })
-    consoleDebugging := zapcore.Lock(os.Stdout)
-    //jsonDebugWriter := getWriter("./logs/trade-debug.log")
-    jsonInfoWriter := getWriter("./logs/trade-info.log")
-    //jsonWarnWriter := getWriter("./logs/trade-warn.log")
-    jsonErrorWriter := getWriter("./logs/trade-error.log")
-    jsonEncoder := zapcore.NewJSONEncoder(cfg)
-    jsonCore := zapcore.NewTee(
-       zapcore.NewCore(jsonEncoder, zapcore.AddSync(jsonInfoWriter), infoLevel),
-       zapcore.NewCore(jsonEncoder,consoleDebugging,lowPriority),
-       zapcore.NewCore(jsonEncoder, zapcore.AddSync(jsonErrorWriter), errorLevel),
-    )
+    return nil
+}
 
-    jsonLog := zap.New(jsonCore, zap.AddCaller(),zap.AddCallerSkip(2)) // 需要传入 zap.AddCaller() 才会显示打日志点的文件名和行数, 有点小坑
-    jsonLogger = jsonLog.Sugar()
+// swap installs next and releases the files of the previous loggers,
+// entries being written concurrently with a re-Init may still go to the old files.
+func swap(next *loggers) {
+    closeMutex.Lock()
+    defer closeMutex.Unlock()
+    prev := current.Load().(*loggers)
+    current.Store(next)
+    prev.json.Sync()
+    prev.text.Sync()
+    closeAll(prev.closers)
+}
 
-    ////获取 info、error日志文件的io.Writer 抽象 getWriter() 在下方实现
-    //textDebugWriter := getWriter("./logs/trade-text-debug.log")
-    textInfoWriter := getWriter("./logs/trade-text-info.log")
-    //textWarnWriter := getWriter("./logs/trade-text-warn.log")
-    textErrorWriter := getWriter("./logs/trade-text-error.log")
+// closeAll closes in reverse order, the deduplication flushes before the files are closed.
+func closeAll(closers []io.Closer) {
+    for i := len(closers) - 1; i >= 0; i-- {
+        closers[i].Close()
+    }
+}
 
-    //最后创建具体的 Logger
-    textCore := zapcore.NewTee(
-    	zapcore.NewCore(textEncoder, zapcore.AddSync(textInfoWriter), infoLevel),
-        zapcore.NewCore(textEncoder,consoleDebugging,lowPriority),
-    	zapcore.NewCore(textEncoder, zapcore.AddSync(textErrorWriter), errorLevel),
-    )
-    //
+// Sync flushes the buffered entries of both loggers, call it before the process exits.
+func Sync() error {
+    l := load()
+    err := l.json.Sync()
+    if textErr := l.text.Sync(); err == nil {
+        err = textErr
+    }
+    return err
+}
 
-        testLog := zap.New(textCore, zap.AddCaller(),zap.AddCallerSkip(1)) // 需要传入 zap.AddCaller() 才会显示打日志点的文件名和行数, 有点小坑
-        textLogger = testLog.Sugar()
+// Close flushes and closes the log files, later entries go to the default stderr logger.
+func Close() error {
+    err := Sync()
+    swap(defaultLoggers())
+    return err
+}
 
+// output is a sink or the console of a logger, the level is given when building the core
+// so the same files can be shared by a logger and the Named loggers.
+type output struct {
+    encoder zapcore.Encoder
+    writer  zapcore.WriteSyncer
+    levels  []string
+    // remote replaces writer for the asynchronous sinks
+    remote *remoteSink
+}
 
+type outputs []output
 
+// core tees the outputs enabled from minLevel.
+func (o outputs) core(minLevel zapcore.LevelEnabler) zapcore.Core {
+    cores := make([]zapcore.Core, 0, len(o))
+    for _, out := range o {
+        if out.remote != nil {
+            cores = append(cores, &remoteCore{levelEnabler(out.levels, minLevel), out.encoder, out.remote})
+            continue
+        }
+        cores = append(cores, zapcore.NewCore(out.encoder, out.writer, levelEnabler(out.levels, minLevel)))
+    }
+    return zapcore.NewTee(cores...)
+}
+
+// buildOutputs opens the sinks and console of lc, the opened files are appended to closers.
+// Their entries are redacted by r.
+func buildOutputs(cfg Config, lc LoggerConfig, r *redactor, closers *[]io.Closer) (outputs, error) {
+    var outs outputs
+    for _, sink := range lc.Sinks {
+        rotation := cfg.Rotation
+        if sink.Rotation != nil {
+            rotation = *sink.Rotation
+            rotation.setDefaults()
+        }
+        writer, err := newRotateWriter(filepath.Join(cfg.Dir, sink.File), rotation)
+        if err != nil {
+            return nil, err
+        }
+        *closers = append(*closers, writer)
+        outs = append(outs, output{encoder: r.wrap(getEncoder(lc.Encoding, false)), writer: zapcore.AddSync(writer), levels: sink.Levels})
+    }
+    for _, rc := range lc.Remotes {
+        rc.setDefaults(cfg.Service)
+        sink, err := newRemoteSink(rc)
+        if err != nil {
+            return nil, err
+        }
+        *closers = append(*closers, sink)
+        outs = append(outs, output{encoder: r.wrap(getEncoder(rc.Encoding, false)), levels: rc.Levels, remote: sink})
+    }
+    if lc.Console {
+        outs = append(outs, output{encoder: r.wrap(getEncoder(lc.ConsoleEncoding, true)), writer: zapcore.Lock(os.Stdout)})
+    }
+    return outs, nil
+}
 
+// levelEnabler enables minLevel and above, or exactly levels when not empty:
+// a sink listing its levels is not filtered by Config.Level nor SetLevel.
+func levelEnabler(levels []string, minLevel zapcore.LevelEnabler) zapcore.LevelEnabler {
+    if len(levels) == 0 {
+        return minLevel
+    }
+    set := make(map[zapcore.Level]bool, len(levels))
+    for _, l := range levels {
+        set[zapLogLevel(l)] = true
+    }
+    return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+        return set[lvl]
+    })
 }
+
 func ShowBeegoTime(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
    // encodeTimeLayout(t, "2006-01-02T15:04:05.000Z0700", enc)
     enc.AppendString(t.Format("2006/01/02 15:04:05.000"))
 }
-func getEncoder() zapcore.Encoder {
+
+// getEncoder returns the json or console encoder, colored levels are only used on the terminal.
+func getEncoder(encoding string, color bool) zapcore.Encoder {
+    levelEncoder := zapcore.CapitalLevelEncoder
+    if color {
+        levelEncoder = zapcore.CapitalColorLevelEncoder
+    }
+    if encoding == "json" {
+        return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+            MessageKey:  "msg",
+            LevelKey:    "level",
+            EncodeLevel: levelEncoder,
+            TimeKey:     "timestamp",
+            EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+                enc.AppendString(t.Format("2006-01-02 15:04:05"))
+            },
+            CallerKey:    "file",
+            EncodeCaller: zapcore.ShortCallerEncoder,
+            EncodeDuration: func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+                enc.AppendInt64(int64(d) / 1000000)
+            },
+        })
+    }
     encoderConfig := zap.NewProductionEncoderConfig()
     encoderConfig.EncodeTime = ShowBeegoTime
-    encoderConfig.EncodeLevel =zapcore.CapitalColorLevelEncoder
+    encoderConfig.EncodeLevel = levelEncoder
     encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
     return zapcore.NewConsoleEncoder(encoderConfig)
-
-
-    //
-    //return zapcore.EncoderConfig{
-    //    // Keys can be anything except the empty string.
-    //    TimeKey:        "T",
-    //    LevelKey:       "L",
-    //    NameKey:        "N",
-    //    CallerKey:      "C",
-    //    MessageKey:     "M",
-    //    StacktraceKey:  "S",
-    //    LineEnding:     zapcore.DefaultLineEnding,
-    //    EncodeLevel:    zapcore.CapitalLevelEncoder,
-    //    EncodeTime:     zapcore.ISO8601TimeEncoder,
-    //    EncodeDuration: zapcore.StringDurationEncoder,
-    //    EncodeCaller:   zapcore.ShortCallerEncoder,
-    //}
-
-
-//
-}
-func getWriterByTime(filename string,hourCount int) io.Writer {
-    // 生成rotatelogs的 Logger 实际生成的文件名 trade-text-debug-YY-mm-dd-HH-MM-SS.log
-    // trade_debug 是指向最新日志的链接
-    // 保存7天内的日志 每1小时(整点)分割一次日志
-    hook, err := rotatelogs.New(
-        // 没有使用go风格反人类的format格式
-        // strings.Replace(filename, ".log", "", -1)+"-%Y-%m-%d-%H-%M-%S.log",
-        strings.Replace(filename, ".log", "", -1)+"-%Y-%m-%d.log",
-        rotatelogs.WithLinkName(filename),
-        rotatelogs.WithMaxAge(time.Hour*time.Duration(hourCount)*7),
-        rotatelogs.WithRotationTime(time.Hour*time.Duration(hourCount)),  //24小时分割一次
-    )
-
-    if err != nil {
-    }
-    return hook
-}
-func getWriter(filename string) io.Writer {
-    // 生成rotatelogs的 Logger 实际生成的文件名 trade-text-debug-YY-mm-dd-HH-MM-SS.log
-    // trade_debug 是指向最新日志的链接
-    // 保存7天内的日志 每1小时(整点)分割一次日志
-    hook, err := rotatelogs.New(
-        // 没有使用go风格反人类的format格式
-        // strings.Replace(filename, ".log", "", -1)+"-%Y-%m-%d-%H-%M-%S.log",
-        strings.Replace(filename, ".log", "", -1)+"-%Y-%m-%d.log",
-        rotatelogs.WithLinkName(filename),
-        rotatelogs.WithMaxAge(time.Hour*24*7),
-        rotatelogs.WithRotationTime(time.Hour*24),  //24小时分割一次
-    )
-
-    if err != nil {
-    }
-    return hook
 }
 
 // Debug ....
 func Debug(args ...interface{}) {
-    jsonLogger.Debug(args...)
+    load().json.Debug(args...)
 }
 
 // Debugf ...
 func Debugf(template string, args ...interface{}) {
-    jsonLogger.Debugf(template, args...)
+    load().json.Debugf(template, args...)
 }
 
 // Info ...
 func Info(args ...interface{}) {
-    jsonLogger.Info(args...)
+    load().json.Info(args...)
 }
 
 // Infof ...
 func Infof(template string, args ...interface{}) {
-    jsonLogger.Infof(template, args...)
+    load().json.Infof(template, args...)
 }
 
 // Warn ...
 func Warn(args ...interface{}) {
-    jsonLogger.Warn(args...)
+    load().json.Warn(args...)
 }
 
 // Warnf ...
 func Warnf(template string, args ...interface{}) {
-    jsonLogger.Warnf(template, args...)
+    load().json.Warnf(template, args...)
 }
 
 // Error ...
 func Error(args ...interface{}) {
-    jsonLogger.Error(args...)
+    load().json.Error(args...)
 }
 
 // Errorf ...
 func Errorf(template string, args ...interface{}) {
-    jsonLogger.Errorf(template, args...)
+    load().json.Errorf(template, args...)
 }
 
 // DPanic ...
 func DPanic(args ...interface{}) {
-    jsonLogger.DPanic(args...)
+    load().json.DPanic(args...)
 }
 
 // DPanicf ...
 func DPanicf(template string, args ...interface{}) {
-    jsonLogger.DPanicf(template, args...)
+    load().json.DPanicf(template, args...)
 }
 
 // Panic ...
 func Panic(args ...interface{}) {
-    jsonLogger.Panic(args...)
+    load().json.Panic(args...)
 }
 
 // Panicf ...
 func Panicf(template string, args ...interface{}) {
-    jsonLogger.Panicf(template, args...)
+    load().json.Panicf(template, args...)
 }
 
 // Fatal ...
 func Fatal(args ...interface{}) {
-    jsonLogger.Fatal(args...)
+    load().json.Fatal(args...)
 }
 
 // Fatalf ...
 func Fatalf(template string, args ...interface{}) {
-    jsonLogger.Fatalf(template, args...)
+    load().json.Fatalf(template, args...)
 }
 
 // CDebug ....
 func CDebug(args ...interface{}) {
-    textLogger.Debug(args...)
+    load().text.Debug(args...)
 }
 
 // CDebugf ...
 func CDebugf(template string, args ...interface{}) {
-    textLogger.Debugf(template, args...)
+    load().text.Debugf(template, args...)
 }
 
 // CInfo ...
 func CInfo(args ...interface{}) {
-    textLogger.Info(args...)
+    load().text.Info(args...)
 }
 
 // CInfof ...
 func CInfof(template string, args ...interface{}) {
-    textLogger.Infof(template, args...)
+    load().text.Infof(template, args...)
 }
 
 // CWarn ...
 func CWarn(args ...interface{}) {
-    textLogger.Warn(args...)
+    load().text.Warn(args...)
 }
 
 // CWarnf ...
 func CWarnf(template string, args ...interface{}) {
-    textLogger.Warnf(template, args...)
+    load().text.Warnf(template, args...)
 }
 
 // CError ...
 func CError(args ...interface{}) {
-    textLogger.Error(args...)
+    load().text.Error(args...)
 }
 
 // CErrorf ...
 func CErrorf(template string, args ...interface{}) {
-    textLogger.Errorf(template, args...)
+    load().text.Errorf(template, args...)
 }
 
 // CDPanic ...
 func CDPanic(args ...interface{}) {
-    textLogger.DPanic(args...)
+    load().text.DPanic(args...)
 }
 
 // CDPanicf ...
 func CDPanicf(template string, args ...interface{}) {
-    textLogger.DPanicf(template, args...)
+    load().text.DPanicf(template, args...)
 }
 
 // CPanic ...
 func CPanic(args ...interface{}) {
-    textLogger.Panic(args...)
+    load().text.Panic(args...)
 }
 
 // CPanicf ...
 func CPanicf(template string, args ...interface{}) {
-    textLogger.Panicf(template, args...)
+    load().text.Panicf(template, args...)
 }
 
 // CFatal ...
 func CFatal(args ...interface{}) {
-    textLogger.Fatal(args...)
+    load().text.Fatal(args...)
 }
 
 // CFatalf ...
 func CFatalf(template string, args ...interface{}) {
-    textLogger.Fatalf(template, args...)
+    load().text.Fatalf(template, args...)
 }
+
+// GetTextLogger returns the logger behind CInfo, CError..., it is replaced by the next Init.
 func GetTextLogger() *zap.SugaredLogger {
-    return textLogger;
+    return load().text
 }
\ No newline at end of file
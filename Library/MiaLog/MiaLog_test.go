@@ -0,0 +1,77 @@
+package MiaLog
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "go.uber.org/zap/zapcore"
+)
+
+func TestLevelEnabler(t *testing.T) {
+    tests := []struct {
+        name    string
+        levels  []string
+        lvl     zapcore.Level
+        enabled bool
+    }{
+        {"no levels below min", nil, zapcore.InfoLevel, false},
+        {"no levels at min", nil, zapcore.WarnLevel, true},
+        {"no levels above min", nil, zapcore.ErrorLevel, true},
+        {"listed below min", []string{"info"}, zapcore.InfoLevel, true},
+        {"not listed", []string{"info"}, zapcore.WarnLevel, false},
+        {"not listed above min", []string{"info"}, zapcore.ErrorLevel, false},
+    }
+    for _, tt := range tests {
+        if got := levelEnabler(tt.levels, zapcore.WarnLevel).Enabled(tt.lvl); got != tt.enabled {
+            t.Errorf("%s: Enabled(%s) = %v, want %v", tt.name, tt.lvl, got, tt.enabled)
+        }
+    }
+}
+
+// TestInitLevelSinks checks the files of DefaultConfig keep their levels
+// when Level is above them, as InitLevel("warn") always did.
+func TestInitLevelSinks(t *testing.T) {
+    cfg := DefaultConfig("warn")
+    cfg.Dir = t.TempDir()
+    cfg.Json.Console = false
+    cfg.Text.Console = false
+    if err := Init(cfg); err != nil {
+        t.Fatal(err)
+    }
+    Info("json info")
+    Warn("json warn")
+    Error("json error")
+    CInfo("text info")
+    CDebug("text debug")
+    if err := Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        file    string
+        want    []string
+        notWant []string
+    }{
+        {"trade-info.log", []string{"json info"}, []string{"json warn", "json error"}},
+        {"trade-error.log", []string{"json error"}, []string{"json info", "json warn"}},
+        {"trade-text-info.log", []string{"text info"}, []string{"text debug"}},
+    }
+    for _, tt := range tests {
+        b, err := ioutil.ReadFile(filepath.Join(cfg.Dir, tt.file))
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, s := range tt.want {
+            if !strings.Contains(string(b), s) {
+                t.Errorf("%s misses %q:\n%s", tt.file, s, b)
+            }
+        }
+        for _, s := range tt.notWant {
+            if strings.Contains(string(b), s) {
+                t.Errorf("%s holds %q:\n%s", tt.file, s, b)
+            }
+        }
+    }
+}
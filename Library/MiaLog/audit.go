@@ -0,0 +1,294 @@
+package MiaLog
+
+import (
+    "bufio"
+    "compress/gzip"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "MiaGame/Library/MiaCrypt"
+)
+
+// ErrAuditNotInitialized is returned by Audit before InitAudit.
+var ErrAuditNotInitialized = errors.New("MiaLog: audit logger is not initialized")
+
+// AuditConfig configures the audit trail, a rotated file of json records each
+// chained to the previous one by an HMAC-SHA256.
+type AuditConfig struct {
+    // Dir defaults to "./logs" and File to "audit.log".
+    Dir  string `yaml:"dir"`
+    File string `yaml:"file"`
+    // Rotation defaults to daily files kept forever.
+    Rotation RotationConfig `yaml:"rotation"`
+    // Key of the HMAC chain, encrypted with MiaCrypt.StringEncrypt when
+    // an encrypt key is given to NewAuditLogger.
+    Key string `yaml:"key"`
+}
+
+// AuditRecord is a line of the audit file. Mac authenticates the other fields,
+// Prev is the Mac of the previous record so a missing or altered record breaks the chain.
+type AuditRecord struct {
+    Seq    uint64          `json:"seq"`
+    Time   string          `json:"time"`
+    Action string          `json:"action"`
+    Actor  string          `json:"actor,omitempty"`
+    Data   json.RawMessage `json:"data,omitempty"`
+    Prev   string          `json:"prev"`
+    Mac    string          `json:"mac,omitempty"`
+}
+
+// sum returns the mac of r, Mac excluded.
+func (r AuditRecord) sum(key []byte) (string, error) {
+    r.Mac = ""
+    body, err := json.Marshal(r)
+    if err != nil {
+        return "", err
+    }
+    mac := hmac.New(sha256.New, key)
+    mac.Write(body)
+    return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// AuditLogger appends chained records to the audit file, it is safe for concurrent use.
+type AuditLogger struct {
+    mutex sync.Mutex
+    w     io.WriteCloser
+    key   []byte
+    seq   uint64
+    prev  string
+}
+
+var auditLogger struct {
+    sync.RWMutex
+    a *AuditLogger
+}
+
+// NewAuditLogger opens the audit file and resumes the chain from its last record.
+// encryptKey decrypts cfg.Key with MiaCrypt, "" when the key is in clear.
+func NewAuditLogger(cfg AuditConfig, encryptKey string) (*AuditLogger, error) {
+    key := cfg.Key
+    if encryptKey != "" {
+        var err error
+        if key, err = MiaCrypt.StringDecrypt(cfg.Key, encryptKey); err != nil {
+            return nil, fmt.Errorf("MiaLog: decrypt audit key: %w", err)
+        }
+    }
+    if key == "" {
+        return nil, errors.New("MiaLog: audit key is required")
+    }
+    if cfg.Dir == "" {
+        cfg.Dir = "./logs"
+    }
+    if cfg.File == "" {
+        cfg.File = "audit.log"
+    }
+    if cfg.Rotation.MaxAge == 0 && cfg.Rotation.MaxBackups <= 0 {
+        cfg.Rotation.MaxAge = -1
+    }
+    cfg.Rotation.setDefaults()
+
+    filename := filepath.Join(cfg.Dir, cfg.File)
+    a := &AuditLogger{key: []byte(key)}
+    // the link still names the last written file
+    if last, err := lastAuditRecord(filename); err != nil {
+        return nil, err
+    } else if last != nil {
+        a.seq, a.prev = last.Seq, last.Mac
+    }
+    w, err := newRotateWriter(filename, cfg.Rotation)
+    if err != nil {
+        return nil, err
+    }
+    a.w = w
+    return a, nil
+}
+
+// InitAudit installs the logger used by Audit.
+func InitAudit(cfg AuditConfig, encryptKey string) error {
+    a, err := NewAuditLogger(cfg, encryptKey)
+    if err != nil {
+        return err
+    }
+    auditLogger.Lock()
+    prev := auditLogger.a
+    auditLogger.a = a
+    auditLogger.Unlock()
+    if prev != nil {
+        prev.Close()
+    }
+    return nil
+}
+
+// Audit records action on the logger installed by InitAudit.
+func Audit(action, actor string, data interface{}) error {
+    auditLogger.RLock()
+    a := auditLogger.a
+    auditLogger.RUnlock()
+    if a == nil {
+        return ErrAuditNotInitialized
+    }
+    return a.Log(action, actor, data)
+}
+
+// Log appends a record, data is encoded in json.
+func (a *AuditLogger) Log(action, actor string, data interface{}) error {
+    var raw json.RawMessage
+    if data != nil {
+        var err error
+        if raw, err = json.Marshal(data); err != nil {
+            return err
+        }
+    }
+
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+    if a.w == nil {
+        return errors.New("MiaLog: audit logger is closed")
+    }
+    r := AuditRecord{
+        Seq:    a.seq + 1,
+        Time:   time.Now().Format(time.RFC3339Nano),
+        Action: action,
+        Actor:  actor,
+        Data:   raw,
+        Prev:   a.prev,
+    }
+    mac, err := r.sum(a.key)
+    if err != nil {
+        return err
+    }
+    r.Mac = mac
+    line, err := json.Marshal(r)
+    if err != nil {
+        return err
+    }
+    if _, err = a.w.Write(append(line, '\n')); err != nil {
+        return err
+    }
+    a.seq, a.prev = r.Seq, r.Mac
+    return nil
+}
+
+// Close closes the audit file.
+func (a *AuditLogger) Close() error {
+    a.mutex.Lock()
+    defer a.mutex.Unlock()
+    if a.w == nil {
+        return nil
+    }
+    err := a.w.Close()
+    a.w = nil
+    return err
+}
+
+func lastAuditRecord(filename string) (*AuditRecord, error) {
+    f, err := os.Open(filename)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    var last *AuditRecord
+    err = readAudit(f, func(_ int, r *AuditRecord, err error) {
+        if err == nil {
+            last = r
+        }
+    })
+    return last, err
+}
+
+// readAudit calls fn for every line of rd, err is the parse error of the line.
+func readAudit(rd io.Reader, fn func(line int, r *AuditRecord, err error)) error {
+    scanner := bufio.NewScanner(rd)
+    scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    for line := 1; scanner.Scan(); line++ {
+        if len(scanner.Bytes()) == 0 {
+            continue
+        }
+        r := &AuditRecord{}
+        fn(line, r, json.Unmarshal(scanner.Bytes(), r))
+    }
+    return scanner.Err()
+}
+
+// AuditIssue is a problem found by VerifyAudit.
+type AuditIssue struct {
+    File   string
+    Line   int
+    Seq    uint64
+    Reason string
+}
+
+func (i AuditIssue) String() string {
+    return fmt.Sprintf("%s:%d seq %d: %s", i.File, i.Line, i.Seq, i.Reason)
+}
+
+// VerifyAudit checks the chain across files, given in chronological order
+// (".gz" files are decompressed). It reports altered records, missing or
+// reordered ones and broken links. The chain may start after seq 1 when the
+// oldest files were removed, and records dropped from the end of the last
+// file can't be detected without the sequence number known elsewhere.
+func VerifyAudit(key []byte, files ...string) ([]AuditIssue, error) {
+    var issues []AuditIssue
+    var prev *AuditRecord
+    for _, file := range files {
+        rd, closer, err := openAuditFile(file)
+        if err != nil {
+            return issues, err
+        }
+        err = readAudit(rd, func(line int, r *AuditRecord, err error) {
+            issue := func(reason string) {
+                issues = append(issues, AuditIssue{File: file, Line: line, Seq: r.Seq, Reason: reason})
+            }
+            if err != nil {
+                issue("unreadable record: " + err.Error())
+                return
+            }
+            if mac, err := r.sum(key); err != nil || !hmac.Equal([]byte(mac), []byte(r.Mac)) {
+                issue("mac mismatch, the record was altered or signed with another key")
+            }
+            switch {
+            case prev == nil && r.Seq == 1 && r.Prev != "":
+                issue("first record links to a previous one")
+            case prev != nil && r.Seq != prev.Seq+1:
+                issue(fmt.Sprintf("expected seq %d, %d records missing or reordered", prev.Seq+1, int64(r.Seq)-int64(prev.Seq)-1))
+            case prev != nil && r.Prev != prev.Mac:
+                issue("prev does not match the mac of the previous record")
+            }
+            prev = r
+        })
+        closer.Close()
+        if err != nil {
+            return issues, err
+        }
+    }
+    return issues, nil
+}
+
+func openAuditFile(file string) (io.Reader, io.Closer, error) {
+    f, err := os.Open(file)
+    if err != nil {
+        return nil, nil, err
+    }
+    if !strings.HasSuffix(file, ".gz") {
+        return f, f, nil
+    }
+    zr, err := gzip.NewReader(f)
+    if err != nil {
+        f.Close()
+        return nil, nil, err
+    }
+    return zr, f, nil
+}
@@ -0,0 +1,102 @@
+package MiaLog
+
+import (
+    "bytes"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+var testAuditKey = []byte("audit key")
+
+// writeAudit logs n records to a new audit file and returns its lines.
+func writeAudit(t *testing.T, n int) [][]byte {
+    dir := t.TempDir()
+    a, err := NewAuditLogger(AuditConfig{Dir: dir, Key: string(testAuditKey)}, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < n; i++ {
+        if err := a.Log("pay", "mch", map[string]interface{}{"total_fee": i}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := a.Close(); err != nil {
+        t.Fatal(err)
+    }
+    b, err := ioutil.ReadFile(filepath.Join(dir, "audit.log"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+}
+
+func verifyLines(t *testing.T, key []byte, lines [][]byte) []AuditIssue {
+    file := filepath.Join(t.TempDir(), "audit.log")
+    if err := ioutil.WriteFile(file, append(bytes.Join(lines, []byte("\n")), '\n'), 0644); err != nil {
+        t.Fatal(err)
+    }
+    issues, err := VerifyAudit(key, file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return issues
+}
+
+func TestVerifyAudit(t *testing.T) {
+    lines := writeAudit(t, 4)
+    if len(lines) != 4 {
+        t.Fatalf("got %d records, want 4", len(lines))
+    }
+    edited := append([][]byte(nil), lines...)
+    edited[1] = bytes.Replace(lines[1], []byte(`"total_fee":1`), []byte(`"total_fee":100`), 1)
+    reordered := [][]byte{lines[0], lines[2], lines[1], lines[3]}
+    dropped := [][]byte{lines[0], lines[2], lines[3]}
+
+    tests := []struct {
+        name  string
+        key   []byte
+        lines [][]byte
+        want  []string // reasons, in order
+    }{
+        {"intact", testAuditKey, lines, nil},
+        {"wrong key", []byte("other key"), lines, []string{"mac mismatch", "mac mismatch", "mac mismatch", "mac mismatch"}},
+        {"edited", testAuditKey, edited, []string{"mac mismatch"}},
+        {"reordered", testAuditKey, reordered, []string{"expected seq 2", "expected seq 4", "expected seq 3"}},
+        {"dropped", testAuditKey, dropped, []string{"expected seq 2"}},
+        {"unreadable", testAuditKey, [][]byte{lines[0], []byte("{"), lines[1]}, []string{"unreadable record"}},
+    }
+    for _, tt := range tests {
+        issues := verifyLines(t, tt.key, tt.lines)
+        if len(issues) != len(tt.want) {
+            t.Errorf("%s: got issues %v, want %q", tt.name, issues, tt.want)
+            continue
+        }
+        for i, issue := range issues {
+            if !strings.HasPrefix(issue.Reason, tt.want[i]) {
+                t.Errorf("%s: issue %d is %v, want %q", tt.name, i, issue, tt.want[i])
+            }
+        }
+    }
+}
+
+// TestAuditResume checks a reopened logger continues the chain of its file.
+func TestAuditResume(t *testing.T) {
+    dir := t.TempDir()
+    cfg := AuditConfig{Dir: dir, Key: string(testAuditKey)}
+    for i := 0; i < 2; i++ {
+        a, err := NewAuditLogger(cfg, "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := a.Log("pay", "mch", nil); err != nil {
+            t.Fatal(err)
+        }
+        a.Close()
+    }
+    issues, err := VerifyAudit(testAuditKey, filepath.Join(dir, "audit.log"))
+    if err != nil || len(issues) != 0 {
+        t.Errorf("VerifyAudit = %v, %v, want no issue", issues, err)
+    }
+}
@@ -0,0 +1,110 @@
+// Command auditverify checks the HMAC chain of MiaLog audit files.
+//
+//  auditverify -key <key> [-encryptkey <key>] logs/audit-*.log*
+//
+// The files are sorted by their first sequence number, it exits with status 1
+// when an issue is found.
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "MiaGame/Library/MiaCrypt"
+    "MiaGame/Library/MiaLog"
+)
+
+func main() {
+    key := flag.String("key", os.Getenv("MIA_AUDIT_KEY"), "HMAC key of the chain, defaults to $MIA_AUDIT_KEY")
+    encryptKey := flag.String("encryptkey", "", "decrypts -key with MiaCrypt")
+    flag.Parse()
+    if *key == "" || flag.NArg() == 0 {
+        fmt.Fprintln(os.Stderr, "usage: auditverify -key <key> [-encryptkey <key>] file...")
+        os.Exit(2)
+    }
+    k := *key
+    if *encryptKey != "" {
+        var err error
+        if k, err = MiaCrypt.StringDecrypt(k, *encryptKey); err != nil {
+            fmt.Fprintln(os.Stderr, "decrypt key:", err)
+            os.Exit(2)
+        }
+    }
+
+    files := sortBySeq(flag.Args())
+    issues, err := MiaLog.VerifyAudit([]byte(k), files...)
+    for _, issue := range issues {
+        fmt.Println(issue)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    if len(issues) > 0 {
+        os.Exit(1)
+    }
+    fmt.Printf("%d files ok\n", len(files))
+}
+
+// sortBySeq orders the files by the seq of their first record, the link to
+// the current file is skipped when it is given with its target.
+func sortBySeq(names []string) []string {
+    type file struct {
+        name string
+        seq  uint64
+    }
+    seen := make(map[string]bool)
+    var files []file
+    for _, name := range names {
+        real := filepath.Clean(name)
+        if target, err := os.Readlink(name); err == nil {
+            if !filepath.IsAbs(target) {
+                target = filepath.Join(filepath.Dir(name), target)
+            }
+            real = filepath.Clean(target)
+        }
+        if seen[real] {
+            continue
+        }
+        seen[real] = true
+        files = append(files, file{name, firstSeq(name)})
+    }
+    sort.SliceStable(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+    sorted := make([]string, len(files))
+    for i, f := range files {
+        sorted[i] = f.name
+    }
+    return sorted
+}
+
+func firstSeq(name string) uint64 {
+    f, err := os.Open(name)
+    if err != nil {
+        return 0
+    }
+    defer f.Close()
+    var rd io.Reader = f
+    if strings.HasSuffix(name, ".gz") {
+        zr, err := gzip.NewReader(f)
+        if err != nil {
+            return 0
+        }
+        rd = zr
+    }
+    scanner := bufio.NewScanner(rd)
+    scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+    if !scanner.Scan() {
+        return 0
+    }
+    var r MiaLog.AuditRecord
+    json.Unmarshal(scanner.Bytes(), &r)
+    return r.Seq
+}
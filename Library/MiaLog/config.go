@@ -0,0 +1,145 @@
+package MiaLog
+
+import "time"
+
+// Config describes the loggers built by Init, it can be loaded from yaml with YamlRead.Load.
+type Config struct {
+    // Level is the minimum level of the console output and of the sinks without Levels:
+    // debug, info, warn or error. Defaults to info.
+    Level string `yaml:"level"`
+    // Service is added as the "service" field of every entry when set.
+    Service string `yaml:"service"`
+    // Dir holds the log files. Defaults to "./logs".
+    Dir string `yaml:"dir"`
+    // Rotation is the default rotation of every sink.
+    Rotation RotationConfig `yaml:"rotation"`
+    // Json configures the logger behind Info, Error... which writes json lines by default.
+    Json LoggerConfig `yaml:"json"`
+    // Text configures the logger behind CInfo, CError... which writes console lines by default.
+    Text LoggerConfig `yaml:"text"`
+    // Sampling and Dedup limit the repeated entries of every logger, both are disabled when nil.
+    Sampling *SamplingConfig `yaml:"sampling"`
+    Dedup    *DedupConfig    `yaml:"dedup"`
+    // CrashDir receives a dump of all the goroutines for every recovered panic, disabled when empty.
+    CrashDir string `yaml:"crashdir"`
+    // Redact masks secrets before the entries are written, DefaultRedactConfig when nil.
+    Redact *RedactConfig `yaml:"redact"`
+    // Modules configures the loggers returned by Named, by name.
+    Modules map[string]ModuleConfig `yaml:"modules"`
+}
+
+// ModuleConfig configures a Named logger, which writes to the Text outputs by default.
+type ModuleConfig struct {
+    // Level of the module, the Text level when empty.
+    Level string `yaml:"level"`
+    // Sinks are dedicated files of the module, encoded with Encoding
+    // which defaults to the Text encoding.
+    Sinks    []SinkConfig `yaml:"sinks"`
+    Encoding string       `yaml:"encoding"`
+    // Exclusive writes the module only to its Sinks.
+    Exclusive bool `yaml:"exclusive"`
+}
+
+// LoggerConfig configures the outputs of one logger.
+type LoggerConfig struct {
+    // Level overrides Config.Level for this logger.
+    Level string `yaml:"level"`
+    // Console enables the colored stdout output.
+    Console bool `yaml:"console"`
+    // ConsoleEncoding is "json" or "console".
+    ConsoleEncoding string `yaml:"consoleencoding"`
+    // Encoding of the file sinks, "json" or "console".
+    Encoding string `yaml:"encoding"`
+    Sinks    []SinkConfig `yaml:"sinks"`
+    // Remotes ship the entries to syslog, an http endpoint or a unix socket.
+    Remotes []RemoteConfig `yaml:"remotes"`
+}
+
+// SinkConfig is a rotated log file.
+type SinkConfig struct {
+    // File name inside Config.Dir, like "trade-info.log". It is a link to the current
+    // rotated file named after Rotation.Pattern, e.g. "trade-info-2006-01-02.log".
+    File string `yaml:"file"`
+    // Levels written to the file whatever Config.Level, all the levels from Config.Level up when empty.
+    Levels []string `yaml:"levels"`
+    // Rotation overrides Config.Rotation for this file.
+    Rotation *RotationConfig `yaml:"rotation"`
+}
+
+// RotationConfig controls when files are rotated and how long they are kept.
+type RotationConfig struct {
+    // Pattern is the strftime suffix inserted before ".log" in rotated file names. Defaults to "-%Y-%m-%d".
+    Pattern string `yaml:"pattern"`
+    // RotationTime rotates the file periodically. Defaults to 24 hours.
+    RotationTime time.Duration `yaml:"rotationtime"`
+    // MaxSize rotates the file once it reaches this many megabytes, 0 disables it.
+    MaxSize int64 `yaml:"maxsize"`
+    // MaxAge deletes rotated files older than this. Defaults to 7 days when MaxBackups is 0,
+    // negative keeps the files forever.
+    MaxAge time.Duration `yaml:"maxage"`
+    // MaxBackups keeps at most this many rotated files, 0 keeps them all.
+    MaxBackups int `yaml:"maxbackups"`
+    // Compress gzips rotated files.
+    Compress bool `yaml:"compress"`
+}
+
+// DefaultConfig returns the layout of InitLevel: for each logger an info file,
+// an error file and the console, rotated daily and kept 7 days.
+func DefaultConfig(level string) Config {
+    return Config{
+        Level: level,
+        Dir:   "./logs",
+        Rotation: RotationConfig{
+            Pattern:      "-%Y-%m-%d",
+            RotationTime: time.Hour * 24,
+            MaxAge:       time.Hour * 24 * 7,
+        },
+        Json: LoggerConfig{
+            Console:         true,
+            ConsoleEncoding: "json",
+            Encoding:        "json",
+            Sinks: []SinkConfig{
+                {File: "trade-info.log", Levels: []string{"info"}},
+                {File: "trade-error.log", Levels: []string{"error"}},
+            },
+        },
+        Text: LoggerConfig{
+            Console:         true,
+            ConsoleEncoding: "console",
+            Encoding:        "console",
+            Sinks: []SinkConfig{
+                {File: "trade-text-info.log", Levels: []string{"info"}},
+                {File: "trade-text-error.log", Levels: []string{"error"}},
+            },
+        },
+    }
+}
+
+func (c *Config) setDefaults() {
+    if c.Level == "" {
+        c.Level = "info"
+    }
+    if c.Dir == "" {
+        c.Dir = "./logs"
+    }
+    c.Rotation.setDefaults()
+}
+
+func (l LoggerConfig) levelOr(level string) string {
+    if l.Level != "" {
+        return l.Level
+    }
+    return level
+}
+
+func (r *RotationConfig) setDefaults() {
+    if r.Pattern == "" {
+        r.Pattern = "-%Y-%m-%d"
+    }
+    if r.RotationTime <= 0 {
+        r.RotationTime = time.Hour * 24
+    }
+    if r.MaxAge == 0 && r.MaxBackups <= 0 {
+        r.MaxAge = time.Hour * 24 * 7
+    }
+}
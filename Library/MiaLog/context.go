@@ -0,0 +1,85 @@
+package MiaLog
+
+import (
+    "context"
+
+    "go.uber.org/zap"
+)
+
+// Field names of the request scoped loggers.
+const (
+    RequestIDKey = "request_id"
+    UserIDKey    = "user_id"
+    TraceIDKey   = "trace_id"
+    SpanIDKey    = "span_id"
+    ServiceKey   = "service"
+    ModuleKey    = "module"
+)
+
+type contextKey struct{}
+
+// contextFields are the key-value pairs attached to a context by WithContext.
+type contextFields struct {
+    fields    []interface{}
+    requestID string
+}
+
+// WithContext returns a copy of ctx whose FromContext logger also carries fields,
+// given as key-value pairs or zap.Field like SugaredLogger.With.
+func WithContext(ctx context.Context, fields ...interface{}) context.Context {
+    parent, _ := ctx.Value(contextKey{}).(*contextFields)
+    next := &contextFields{}
+    if parent != nil {
+        next.fields = append(next.fields, parent.fields...)
+        next.requestID = parent.requestID
+    }
+    next.fields = append(next.fields, fields...)
+    for i := 0; i+1 < len(fields); i++ {
+        if key, ok := fields[i].(string); ok {
+            if key == RequestIDKey {
+                if id, ok := fields[i+1].(string); ok {
+                    next.requestID = id
+                }
+            }
+            i++
+        }
+    }
+    return context.WithValue(ctx, contextKey{}, next)
+}
+
+// WithRequestID attaches the request id to ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+    return WithContext(ctx, RequestIDKey, id)
+}
+
+// WithUserID attaches the user id to ctx.
+func WithUserID(ctx context.Context, id interface{}) context.Context {
+    return WithContext(ctx, UserIDKey, id)
+}
+
+// WithTrace attaches the trace and span ids to ctx.
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+    return WithContext(ctx, TraceIDKey, traceID, SpanIDKey, spanID)
+}
+
+// RequestID returns the request id attached to ctx, "" if none.
+func RequestID(ctx context.Context) string {
+    if f, ok := ctx.Value(contextKey{}).(*contextFields); ok {
+        return f.requestID
+    }
+    return ""
+}
+
+// FromContext returns the json logger with the fields attached to ctx,
+// it follows the configuration of the last Init.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+    // the json logger skips the frames of the package functions
+    l := load().json.Desugar().WithOptions(zap.AddCallerSkip(-2)).Sugar()
+    if ctx == nil {
+        return l
+    }
+    if f, ok := ctx.Value(contextKey{}).(*contextFields); ok && len(f.fields) > 0 {
+        return l.With(f.fields...)
+    }
+    return l
+}
@@ -1,3 +1,7 @@
 module MiaGame/Library/MiaLog
 
 go 1.16
+
+require MiaGame/Library/MiaCrypt v0.0.0
+
+replace MiaGame/Library/MiaCrypt => ../MiaCrypt
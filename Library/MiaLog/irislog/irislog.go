@@ -0,0 +1,25 @@
+// Package irislog is the iris flavour of the MiaLog request middleware, kept
+// apart so only the services built on iris depend on it.
+package irislog
+
+import (
+    "github.com/kataras/iris/v12"
+    "go.uber.org/zap"
+
+    "MiaGame/Library/MiaLog"
+)
+
+// Handler is the iris middleware equivalent of MiaLog.Handler, handlers get the logger with FromContext.
+func Handler() iris.Handler {
+    return func(ctx iris.Context) {
+        r := MiaLog.WithRequest(ctx.Request())
+        ctx.ResetRequest(r)
+        ctx.Header(MiaLog.RequestIDHeader, MiaLog.RequestID(r.Context()))
+        ctx.Next()
+    }
+}
+
+// FromContext returns MiaLog.FromContext of the iris request.
+func FromContext(ctx iris.Context) *zap.SugaredLogger {
+    return MiaLog.FromContext(ctx.Request().Context())
+}
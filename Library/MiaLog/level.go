@@ -0,0 +1,228 @@
+package MiaLog
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sync"
+
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+)
+
+// The levels are shared by every Init, so they can be changed at runtime
+// and survive a reconfiguration.
+var (
+    jsonLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+    textLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+    levelMutex    sync.RWMutex
+    initJsonLevel = zap.InfoLevel
+    initTextLevel = zap.InfoLevel
+    // modules without a level follow textLevel
+    moduleLevels     = make(map[string]zap.AtomicLevel)
+    initModuleLevels = make(map[string]zapcore.Level)
+)
+
+func setInitLevels(jsonLvl, textLvl zapcore.Level, modules map[string]zapcore.Level) {
+    levelMutex.Lock()
+    defer levelMutex.Unlock()
+    initJsonLevel, initTextLevel, initModuleLevels = jsonLvl, textLvl, modules
+    jsonLevel.SetLevel(jsonLvl)
+    textLevel.SetLevel(textLvl)
+    resetModuleLevels()
+}
+
+// resetModuleLevels restores initModuleLevels, levelMutex must be held.
+func resetModuleLevels() {
+    for name := range moduleLevels {
+        if _, ok := initModuleLevels[name]; !ok {
+            delete(moduleLevels, name)
+        }
+    }
+    for name, lvl := range initModuleLevels {
+        if l, ok := moduleLevels[name]; ok {
+            l.SetLevel(lvl)
+        } else {
+            moduleLevels[name] = zap.NewAtomicLevelAt(lvl)
+        }
+    }
+}
+
+func moduleLevel(module string) zap.AtomicLevel {
+    levelMutex.RLock()
+    l, ok := moduleLevels[module]
+    levelMutex.RUnlock()
+    if ok {
+        return l
+    }
+    return textLevel
+}
+
+// SetModuleLevel changes the level of the Named logger module,
+// an empty level makes it follow the CInfo, CError... level again.
+func SetModuleLevel(module, level string) error {
+    if level == "" {
+        levelMutex.Lock()
+        delete(moduleLevels, module)
+        levelMutex.Unlock()
+        return nil
+    }
+    lvl, err := parseLevel(level)
+    if err != nil {
+        return err
+    }
+    levelMutex.Lock()
+    defer levelMutex.Unlock()
+    if l, ok := moduleLevels[module]; ok {
+        l.SetLevel(lvl)
+    } else {
+        moduleLevels[module] = zap.NewAtomicLevelAt(lvl)
+    }
+    return nil
+}
+
+// ModuleLevels returns the modules having their own level.
+func ModuleLevels() map[string]string {
+    levelMutex.RLock()
+    defer levelMutex.RUnlock()
+    levels := make(map[string]string, len(moduleLevels))
+    for name, l := range moduleLevels {
+        levels[name] = l.Level().String()
+    }
+    return levels
+}
+
+// JsonLevel returns the level of Info, Error...
+func JsonLevel() zap.AtomicLevel {
+    return jsonLevel
+}
+
+// TextLevel returns the level of CInfo, CError...
+func TextLevel() zap.AtomicLevel {
+    return textLevel
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+    var lvl zapcore.Level
+    err := lvl.UnmarshalText([]byte(level))
+    return lvl, err
+}
+
+// SetLevel changes the level of both loggers: debug, info, warn or error.
+func SetLevel(level string) error {
+    lvl, err := parseLevel(level)
+    if err != nil {
+        return err
+    }
+    jsonLevel.SetLevel(lvl)
+    textLevel.SetLevel(lvl)
+    return nil
+}
+
+// EnableDebug switches both loggers to debug, ResetLevel reverts it.
+// MiaSystem.InitSignal calls them on SIGUSR1 and SIGUSR2.
+func EnableDebug() {
+    levelMutex.RLock()
+    jsonLevel.SetLevel(zap.DebugLevel)
+    textLevel.SetLevel(zap.DebugLevel)
+    for _, l := range moduleLevels {
+        l.SetLevel(zap.DebugLevel)
+    }
+    levelMutex.RUnlock()
+    CWarn("MiaLog: debug level enabled")
+}
+
+// ResetLevel restores the levels configured by the last Init, the module levels included.
+func ResetLevel() {
+    levelMutex.Lock()
+    jsonLevel.SetLevel(initJsonLevel)
+    textLevel.SetLevel(initTextLevel)
+    resetModuleLevels()
+    levelMutex.Unlock()
+    CWarnf("MiaLog: level reset to json %s, text %s", jsonLevel.Level(), textLevel.Level())
+}
+
+type levelPayload struct {
+    Level   string            `json:"level,omitempty"`
+    Json    string            `json:"json,omitempty"`
+    Text    string            `json:"text,omitempty"`
+    Modules map[string]string `json:"modules,omitempty"`
+}
+
+// LevelHandler serves the levels for an admin endpoint:
+// GET returns {"json":"info","text":"info","modules":{"redis":"debug"}},
+// PUT {"level":"debug"} sets both loggers, {"json":"debug"} or {"text":"debug"}
+// a single one and {"modules":{"redis":"debug"}} a Named logger, "" resets a module.
+func LevelHandler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        switch r.Method {
+        case http.MethodGet:
+        case http.MethodPut:
+            var req levelPayload
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeLevelError(w, http.StatusBadRequest, err)
+                return
+            }
+            if err := applyLevels(req); err != nil {
+                writeLevelError(w, http.StatusBadRequest, err)
+                return
+            }
+            CWarnf("MiaLog: level changed to json %s, text %s, modules %v by %s", jsonLevel.Level(), textLevel.Level(), ModuleLevels(), r.RemoteAddr)
+        default:
+            w.Header().Set("Allow", "GET, PUT")
+            writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+            return
+        }
+        json.NewEncoder(w).Encode(levelPayload{
+            Json:    jsonLevel.Level().String(),
+            Text:    textLevel.Level().String(),
+            Modules: ModuleLevels(),
+        })
+    })
+}
+
+// applyLevels validates every level before changing any.
+func applyLevels(req levelPayload) error {
+    if req.Level == "" && req.Json == "" && req.Text == "" && len(req.Modules) == 0 {
+        return fmt.Errorf("level, json, text or modules is required")
+    }
+    jsonLvl, textLvl := jsonLevel.Level(), textLevel.Level()
+    var err error
+    if req.Level != "" {
+        if jsonLvl, err = parseLevel(req.Level); err != nil {
+            return err
+        }
+        textLvl = jsonLvl
+    }
+    if req.Json != "" {
+        if jsonLvl, err = parseLevel(req.Json); err != nil {
+            return err
+        }
+    }
+    if req.Text != "" {
+        if textLvl, err = parseLevel(req.Text); err != nil {
+            return err
+        }
+    }
+    for name, level := range req.Modules {
+        if level == "" {
+            continue
+        }
+        if _, err = parseLevel(level); err != nil {
+            return fmt.Errorf("module %s: %w", name, err)
+        }
+    }
+    jsonLevel.SetLevel(jsonLvl)
+    textLevel.SetLevel(textLvl)
+    for name, level := range req.Modules {
+        SetModuleLevel(name, level)
+    }
+    return nil
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+    w.WriteHeader(code)
+    json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,189 @@
+package MiaLog
+
+import (
+    "fmt"
+    "io"
+    "sync"
+    "time"
+
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+)
+
+// maxDedupKeys bounds the memory of the deduplication, messages beyond it are not deduplicated.
+const maxDedupKeys = 10000
+
+// SamplingConfig is the zap sampling: per Tick, the first Initial entries with
+// a given level and message are logged, then one every Thereafter.
+type SamplingConfig struct {
+    // Tick defaults to 1 second.
+    Tick time.Duration `yaml:"tick"`
+    // Initial defaults to 100.
+    Initial int `yaml:"initial"`
+    // Thereafter defaults to 100, 0 drops everything after Initial.
+    Thereafter int `yaml:"thereafter"`
+}
+
+// DedupConfig logs the first entry with a given level and message of each Window,
+// the next ones are counted and summarized as "msg (repeated N times in last 10s)".
+type DedupConfig struct {
+    // Window defaults to 10 seconds.
+    Window time.Duration `yaml:"window"`
+    // Level is the lowest deduplicated level, defaults to info.
+    Level string `yaml:"level"`
+}
+
+// limit wraps core with the deduplication and sampling of cfg,
+// the deduplication is appended to closers to flush its pending summaries.
+func (cfg Config) limit(core zapcore.Core, closers *[]io.Closer) zapcore.Core {
+    if s := cfg.Sampling; s != nil {
+        tick, initial := s.Tick, s.Initial
+        if tick <= 0 {
+            tick = time.Second
+        }
+        if initial <= 0 {
+            initial = 100
+        }
+        core = zapcore.NewSamplerWithOptions(core, tick, initial, s.Thereafter)
+    }
+    if d := cfg.Dedup; d != nil {
+        dedup := newDedupCore(core, *d)
+        *closers = append(*closers, dedup.state)
+        core = dedup
+    }
+    return core
+}
+
+type dedupKey struct {
+    level   zapcore.Level
+    message string
+}
+
+type dedupEntry struct {
+    ent   zapcore.Entry
+    count int
+}
+
+type dedupState struct {
+    window   time.Duration
+    minLevel zapcore.Level
+    // summaries are written without the fields added by With
+    base    zapcore.Core
+    mutex   sync.Mutex
+    entries map[dedupKey]*dedupEntry
+    done    chan struct{}
+    stopped chan struct{}
+    once    sync.Once
+}
+
+// dedupCore suppresses the repeated messages of its core.
+type dedupCore struct {
+    zapcore.Core
+    state *dedupState
+}
+
+func newDedupCore(core zapcore.Core, cfg DedupConfig) *dedupCore {
+    if cfg.Window <= 0 {
+        cfg.Window = 10 * time.Second
+    }
+    minLevel := zap.InfoLevel
+    if cfg.Level != "" {
+        minLevel = zapLogLevel(cfg.Level)
+    }
+    state := &dedupState{
+        window:   cfg.Window,
+        minLevel: minLevel,
+        base:     core,
+        entries:  make(map[dedupKey]*dedupEntry),
+        done:     make(chan struct{}),
+        stopped:  make(chan struct{}),
+    }
+    go state.run()
+    return &dedupCore{Core: core, state: state}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+    return &dedupCore{Core: c.Core.With(fields), state: c.state}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+    if !c.Enabled(ent.Level) {
+        return ce
+    }
+    if ent.Level >= c.state.minLevel && !c.state.allow(ent) {
+        return ce
+    }
+    return c.Core.Check(ent, ce)
+}
+
+// allow reports whether ent is the first of its window.
+func (s *dedupState) allow(ent zapcore.Entry) bool {
+    key := dedupKey{ent.Level, ent.Message}
+    s.mutex.Lock()
+    e, ok := s.entries[key]
+    if ok && ent.Time.Sub(e.ent.Time) < s.window {
+        e.count++
+        s.mutex.Unlock()
+        return false
+    }
+    var expired dedupEntry
+    if ok {
+        expired = *e
+    }
+    if ok || len(s.entries) < maxDedupKeys {
+        s.entries[key] = &dedupEntry{ent: ent}
+    }
+    s.mutex.Unlock()
+    s.summarize(expired)
+    return true
+}
+
+// run writes the summaries of the messages which stopped repeating.
+func (s *dedupState) run() {
+    ticker := time.NewTicker(s.window / 2)
+    defer close(s.stopped)
+    defer ticker.Stop()
+    for {
+        select {
+        case now := <-ticker.C:
+            s.flush(now, false)
+        case <-s.done:
+            s.flush(time.Now(), true)
+            return
+        }
+    }
+}
+
+func (s *dedupState) flush(now time.Time, all bool) {
+    var expired []dedupEntry
+    s.mutex.Lock()
+    for key, e := range s.entries {
+        if all || now.Sub(e.ent.Time) >= s.window {
+            expired = append(expired, *e)
+            delete(s.entries, key)
+        }
+    }
+    s.mutex.Unlock()
+    for _, e := range expired {
+        s.summarize(e)
+    }
+}
+
+func (s *dedupState) summarize(e dedupEntry) {
+    if e.count == 0 {
+        return
+    }
+    ent := e.ent
+    ent.Message = fmt.Sprintf("%s (repeated %d times in last %s)", ent.Message, e.count, s.window)
+    ent.Time = time.Now()
+    if ce := s.base.Check(ent, nil); ce != nil {
+        ce.Write(zap.Int("repeated", e.count))
+    }
+}
+
+// Close stops the flusher and writes the pending summaries.
+func (s *dedupState) Close() error {
+    s.once.Do(func() { close(s.done) })
+    <-s.stopped
+    return nil
+}
@@ -0,0 +1,35 @@
+package MiaLog
+
+import "sync/atomic"
+
+// Metrics receives the instrumentation of MiaLog, MiaPrometheus.LogCollector implements it.
+type Metrics interface {
+    // IncDropped counts the entries lost by a remote sink,
+    // reason is "buffer full", "send error" or "closed".
+    IncDropped(sink, reason string, n int)
+    // IncPanic counts the panics recovered by Recover, RecoverError and SafeGo
+    // by the function which panicked.
+    IncPanic(function string)
+}
+
+type metricsHolder struct {
+    m Metrics
+}
+
+var metrics atomic.Value // metricsHolder
+
+// SetMetrics starts reporting to m, nil stops it.
+func SetMetrics(m Metrics) {
+    metrics.Store(metricsHolder{m})
+}
+
+func loadMetrics() Metrics {
+    h, _ := metrics.Load().(metricsHolder)
+    return h.m
+}
+
+func incDropped(sink, reason string, n int) {
+    if m := loadMetrics(); m != nil && n > 0 {
+        m.IncDropped(sink, reason, n)
+    }
+}
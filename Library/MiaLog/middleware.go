@@ -0,0 +1,60 @@
+package MiaLog
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "net/http"
+    "strings"
+)
+
+// RequestIDHeader is read from the request and echoed in the response by the middlewares.
+const RequestIDHeader = "X-Request-Id"
+
+// Handler is a net/http middleware attaching a request id, and the trace of a W3C
+// traceparent header, to the request context so FromContext can be used by handlers.
+// The incoming X-Request-Id is kept, otherwise a new one is generated.
+func Handler(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        r = WithRequest(r)
+        w.Header().Set(RequestIDHeader, RequestID(r.Context()))
+        next.ServeHTTP(w, r)
+    })
+}
+
+// WithRequest returns r with the request id and trace of its headers attached to
+// its context, like Handler does, for the middlewares of other frameworks.
+func WithRequest(r *http.Request) *http.Request {
+    id := r.Header.Get(RequestIDHeader)
+    // don't let clients inject arbitrary content in the logs
+    if id == "" || len(id) > 128 || strings.ContainsAny(id, "\r\n") {
+        id = newRequestID()
+    }
+    ctx := WithRequestID(r.Context(), id)
+    if traceID, spanID, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+        ctx = WithTrace(ctx, traceID, spanID)
+    }
+    return r.WithContext(ctx)
+}
+
+func newRequestID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return ""
+    }
+    return hex.EncodeToString(b)
+}
+
+// parseTraceparent reads "version-traceid-parentid-flags".
+func parseTraceparent(h string) (string, string, bool) {
+    parts := strings.Split(h, "-")
+    if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+        return "", "", false
+    }
+    if _, err := hex.DecodeString(parts[1]); err != nil {
+        return "", "", false
+    }
+    if _, err := hex.DecodeString(parts[2]); err != nil {
+        return "", "", false
+    }
+    return parts[1], parts[2], true
+}
@@ -0,0 +1,62 @@
+package MiaLog
+
+import (
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+)
+
+// Named returns the logger of a module, like "redis" or "wx". Its entries carry
+// the module field, are filtered by the module level (see SetModuleLevel and
+// Config.Modules) and go to the Text outputs or to the module files.
+// The logger follows later Init calls, so it can be kept in a package variable.
+func Named(module string) *zap.SugaredLogger {
+    return zap.New(&moduleCore{module: module}, zap.AddCaller()).Sugar()
+}
+
+// moduleCore forwards the entries to the outputs of the current loggers.
+type moduleCore struct {
+    module string
+    fields []zapcore.Field
+}
+
+func (c *moduleCore) Enabled(lvl zapcore.Level) bool {
+    return moduleLevel(c.module).Enabled(lvl)
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+    clone := &moduleCore{module: c.module, fields: make([]zapcore.Field, 0, len(c.fields)+len(fields))}
+    clone.fields = append(append(clone.fields, c.fields...), fields...)
+    return clone
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+    if c.Enabled(ent.Level) {
+        return ce.AddCore(ent, c)
+    }
+    return ce
+}
+
+func (c *moduleCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+    l := load()
+    all := make([]zapcore.Field, 0, len(l.fields)+1+len(c.fields)+len(fields))
+    all = append(all, l.fields...)
+    all = append(all, zap.String(ModuleKey, c.module))
+    all = append(all, c.fields...)
+    all = append(all, fields...)
+    // the outputs still apply the levels of their sinks
+    if ce := l.moduleOutputs(c.module).Check(ent, nil); ce != nil {
+        ce.Write(all...)
+    }
+    return nil
+}
+
+func (c *moduleCore) Sync() error {
+    return load().moduleOutputs(c.module).Sync()
+}
+
+func (l *loggers) moduleOutputs(module string) zapcore.Core {
+    if core, ok := l.modules[module]; ok {
+        return core
+    }
+    return l.textAll
+}
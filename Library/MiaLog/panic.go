@@ -0,0 +1,147 @@
+package MiaLog
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime"
+    "runtime/debug"
+    "strconv"
+    "strings"
+    "time"
+
+    "go.uber.org/zap"
+)
+
+// maxCrashStack bounds the dump of all the goroutines written to the crash files.
+const maxCrashStack = 4 << 20
+
+// PanicError is the error set by RecoverError.
+type PanicError struct {
+    Value interface{}
+    Stack []byte
+}
+
+func (e *PanicError) Error() string {
+    return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+    err, _ := e.Value.(error)
+    return err
+}
+
+// Recover logs a panic with its stack and lets the goroutine return normally:
+//
+//  defer MiaLog.Recover()
+//
+// It must be deferred directly, calling it from another deferred function does not recover.
+func Recover() {
+    if p := recover(); p != nil {
+        handlePanic(p, "")
+    }
+}
+
+// RecoverError is Recover setting *err to a *PanicError, for functions returning an error:
+//
+//  func f() (err error) {
+//      defer MiaLog.RecoverError(&err)
+func RecoverError(err *error) {
+    if p := recover(); p != nil {
+        stack := handlePanic(p, "")
+        if err != nil {
+            *err = &PanicError{Value: p, Stack: stack}
+        }
+    }
+}
+
+// SafeGo runs fn in a new goroutine recovering its panics,
+// the log tells where the goroutine was started.
+func SafeGo(fn func()) {
+    spawnedAt := ""
+    if _, file, line, ok := runtime.Caller(1); ok {
+        spawnedAt = filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+    }
+    go func() {
+        defer func() {
+            if p := recover(); p != nil {
+                handlePanic(p, spawnedAt)
+            }
+        }()
+        fn()
+    }()
+}
+
+// handlePanic logs p, counts it and writes the crash dump, it returns the stack of the goroutine.
+func handlePanic(p interface{}, spawnedAt string) []byte {
+    stack := debug.Stack()
+    function := panicFunction()
+    goroutine := goroutineID(stack)
+
+    fields := []interface{}{"panic", fmt.Sprint(p), "func", function, "goroutine", goroutine}
+    if spawnedAt != "" {
+        fields = append(fields, "spawned_at", spawnedAt)
+    }
+    l := load()
+    if l.crashDir != "" {
+        if name, err := writeCrashDump(l.crashDir, p, goroutine); err != nil {
+            fields = append(fields, "crash_dump_error", err.Error())
+        } else {
+            fields = append(fields, "crash_dump", name)
+        }
+    }
+    fields = append(fields, "stack", string(stack))
+    // the location is the panic, given by func and stack
+    l.text.Desugar().WithOptions(zap.WithCaller(false)).Sugar().Errorw("panic recovered", fields...)
+
+    if m := loadMetrics(); m != nil {
+        m.IncPanic(function)
+    }
+    return stack
+}
+
+// panicFunction returns the function which panicked, the first frame after runtime.gopanic.
+func panicFunction() string {
+    pcs := make([]uintptr, 32)
+    frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+    panicking := false
+    for {
+        frame, more := frames.Next()
+        if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+            return frame.Function
+        }
+        if frame.Function == "runtime.gopanic" {
+            panicking = true
+        }
+        if !more {
+            return "unknown"
+        }
+    }
+}
+
+// goroutineID reads the id in the "goroutine 12 [running]:" header of a stack.
+func goroutineID(stack []byte) string {
+    stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+    if idx := bytes.IndexByte(stack, ' '); idx > 0 {
+        return string(stack[:idx])
+    }
+    return ""
+}
+
+// writeCrashDump writes the panic and the stacks of all the goroutines into dir.
+func writeCrashDump(dir string, p interface{}, goroutine string) (string, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return "", err
+    }
+    now := time.Now()
+    name := filepath.Join(dir, fmt.Sprintf("crash-%s-%d-%s.log", now.Format("20060102-150405.000"), os.Getpid(), goroutine))
+    buf := make([]byte, maxCrashStack)
+    buf = buf[:runtime.Stack(buf, true)]
+
+    var dump bytes.Buffer
+    fmt.Fprintf(&dump, "time: %s\npid: %d\nprogram: %s\ngoroutine: %s\npanic: %v\n\n", now.Format(time.RFC3339Nano), os.Getpid(), os.Args[0], goroutine, p)
+    dump.Write(buf)
+    return name, os.WriteFile(name, dump.Bytes(), 0644)
+}
@@ -0,0 +1,473 @@
+package MiaLog
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "time"
+
+    "go.uber.org/zap/zapcore"
+)
+
+// RemoteConfig is an asynchronous sink shipping the entries of a logger.
+// Entries are queued in a bounded buffer and sent by a background goroutine,
+// the lost ones are reported to the Metrics set by SetMetrics.
+type RemoteConfig struct {
+    // Name of the sink in the metrics, defaults to Type:Addr.
+    Name string `yaml:"name"`
+    // Type is "syslog" (RFC5424), "http" (batches of entries) or "unix" (lines on a local socket).
+    Type string `yaml:"type"`
+    // Network is "udp" (default) or "tcp" for syslog, "unix" (default) or "unixgram" for unix.
+    Network string `yaml:"network"`
+    // Addr is the host:port of syslog, the url of http and the socket path of unix.
+    Addr string `yaml:"addr"`
+    // Format of the http batches: "json" array (default), "loki" push api or "elasticsearch" bulk api.
+    Format string `yaml:"format"`
+    // Index of the elasticsearch documents, "" when the url already names it.
+    Index string `yaml:"index"`
+    // Labels of the loki stream, defaults to the service.
+    Labels map[string]string `yaml:"labels"`
+    // Headers added to the http requests, e.g. Authorization.
+    Headers map[string]string `yaml:"headers"`
+    // Encoding of the entries, "json" (default) or "console". The http formats need json.
+    Encoding string `yaml:"encoding"`
+    // Levels sent, all the levels of the logger when empty.
+    Levels []string `yaml:"levels"`
+    // Facility of the syslog messages, defaults to 16 (local0).
+    Facility int `yaml:"facility"`
+    // AppName of the syslog messages, defaults to Config.Service or the program name.
+    AppName string `yaml:"appname"`
+    // BufferSize is the number of queued entries, defaults to 1024.
+    BufferSize int `yaml:"buffersize"`
+    // Policy when the buffer is full: "drop" (default) the entry or "block" the caller.
+    Policy string `yaml:"policy"`
+    // BatchSize defaults to 100 entries and FlushInterval to 1 second.
+    BatchSize     int           `yaml:"batchsize"`
+    FlushInterval time.Duration `yaml:"flushinterval"`
+    // Timeout of the connections and requests, defaults to 5 seconds.
+    Timeout time.Duration `yaml:"timeout"`
+}
+
+func (rc *RemoteConfig) setDefaults(service string) {
+    if rc.Name == "" {
+        rc.Name = rc.Type + ":" + rc.Addr
+    }
+    if rc.Encoding == "" {
+        rc.Encoding = "json"
+    }
+    if rc.Facility == 0 {
+        rc.Facility = 16
+    }
+    if rc.AppName == "" {
+        rc.AppName = service
+    }
+    if rc.AppName == "" {
+        rc.AppName = filepath.Base(os.Args[0])
+    }
+    if len(rc.Labels) == 0 && service != "" {
+        rc.Labels = map[string]string{ServiceKey: service}
+    }
+    if rc.BufferSize <= 0 {
+        rc.BufferSize = 1024
+    }
+    if rc.BatchSize <= 0 {
+        rc.BatchSize = 100
+    }
+    if rc.FlushInterval <= 0 {
+        rc.FlushInterval = time.Second
+    }
+    if rc.Timeout <= 0 {
+        rc.Timeout = 5 * time.Second
+    }
+}
+
+type remoteEntry struct {
+    level zapcore.Level
+    time  time.Time
+    line  []byte
+}
+
+// transport sends a batch and returns how many entries were sent.
+type transport interface {
+    send(entries []remoteEntry) (int, error)
+    close() error
+}
+
+func newTransport(rc RemoteConfig) (transport, error) {
+    switch rc.Type {
+    case "syslog":
+        if rc.Network == "" {
+            rc.Network = "udp"
+        }
+        if rc.Network != "udp" && rc.Network != "tcp" {
+            return nil, fmt.Errorf("MiaLog: syslog network %q is not udp or tcp", rc.Network)
+        }
+        hostname, _ := os.Hostname()
+        return &connTransport{network: rc.Network, addr: rc.Addr, timeout: rc.Timeout, encode: syslogEncoder(rc, hostname)}, nil
+    case "unix":
+        if rc.Network == "" {
+            rc.Network = "unix"
+        }
+        if rc.Network != "unix" && rc.Network != "unixgram" {
+            return nil, fmt.Errorf("MiaLog: unix network %q is not unix or unixgram", rc.Network)
+        }
+        stream := rc.Network == "unix"
+        return &connTransport{network: rc.Network, addr: rc.Addr, timeout: rc.Timeout, encode: func(e remoteEntry) []byte {
+            if stream {
+                return append(e.line, '\n')
+            }
+            return e.line
+        }}, nil
+    case "http":
+        switch rc.Format {
+        case "", "json", "loki", "elasticsearch":
+        default:
+            return nil, fmt.Errorf("MiaLog: unknown http format %q", rc.Format)
+        }
+        if rc.Encoding != "json" {
+            return nil, fmt.Errorf("MiaLog: http sink %s needs the json encoding", rc.Name)
+        }
+        return &httpTransport{rc: rc, client: &http.Client{Timeout: rc.Timeout}}, nil
+    }
+    return nil, fmt.Errorf("MiaLog: unknown remote type %q", rc.Type)
+}
+
+// remoteSink queues the entries for the background goroutine.
+type remoteSink struct {
+    name      string
+    block     bool
+    batchSize int
+    interval  time.Duration
+    timeout   time.Duration
+    t         transport
+
+    mutex   sync.RWMutex
+    closed  bool
+    queue   chan remoteEntry
+    flush   chan chan struct{}
+    done    chan struct{}
+    lastErr string
+}
+
+func newRemoteSink(rc RemoteConfig) (*remoteSink, error) {
+    if rc.Policy != "" && rc.Policy != "drop" && rc.Policy != "block" {
+        return nil, fmt.Errorf("MiaLog: unknown remote policy %q", rc.Policy)
+    }
+    t, err := newTransport(rc)
+    if err != nil {
+        return nil, err
+    }
+    s := &remoteSink{
+        name:      rc.Name,
+        block:     rc.Policy == "block",
+        batchSize: rc.BatchSize,
+        interval:  rc.FlushInterval,
+        timeout:   rc.Timeout,
+        t:         t,
+        queue:     make(chan remoteEntry, rc.BufferSize),
+        flush:     make(chan chan struct{}),
+        done:      make(chan struct{}),
+    }
+    go s.run()
+    return s, nil
+}
+
+func (s *remoteSink) enqueue(e remoteEntry) {
+    s.mutex.RLock()
+    defer s.mutex.RUnlock()
+    if s.closed {
+        incDropped(s.name, "closed", 1)
+        return
+    }
+    if s.block {
+        s.queue <- e
+        return
+    }
+    select {
+    case s.queue <- e:
+    default:
+        incDropped(s.name, "buffer full", 1)
+    }
+}
+
+func (s *remoteSink) run() {
+    ticker := time.NewTicker(s.interval)
+    defer ticker.Stop()
+    batch := make([]remoteEntry, 0, s.batchSize)
+    send := func() {
+        if len(batch) == 0 {
+            return
+        }
+        sent, err := s.t.send(batch)
+        if err != nil {
+            incDropped(s.name, "send error", len(batch)-sent)
+        }
+        s.report(err)
+        batch = batch[:0]
+    }
+    for {
+        select {
+        case e, ok := <-s.queue:
+            if !ok {
+                send()
+                s.t.close()
+                close(s.done)
+                return
+            }
+            if batch = append(batch, e); len(batch) >= s.batchSize {
+                send()
+            }
+        case <-ticker.C:
+            send()
+        case ack := <-s.flush:
+            for len(s.queue) > 0 {
+                if batch = append(batch, <-s.queue); len(batch) >= s.batchSize {
+                    send()
+                }
+            }
+            send()
+            close(ack)
+        }
+    }
+}
+
+// report writes the send errors to stderr when they change, MiaLog can't log its own failures.
+func (s *remoteSink) report(err error) {
+    msg := ""
+    if err != nil {
+        msg = err.Error()
+    }
+    if msg == s.lastErr {
+        return
+    }
+    if err != nil {
+        os.Stderr.WriteString("MiaLog: remote sink " + s.name + ": " + msg + "\n")
+    } else {
+        os.Stderr.WriteString("MiaLog: remote sink " + s.name + " recovered\n")
+    }
+    s.lastErr = msg
+}
+
+// Sync sends the queued entries.
+func (s *remoteSink) Sync() error {
+    ack := make(chan struct{})
+    select {
+    case s.flush <- ack:
+    case <-s.done:
+        return nil
+    }
+    select {
+    case <-ack:
+        return nil
+    case <-time.After(s.timeout):
+        return fmt.Errorf("MiaLog: remote sink %s: sync timeout", s.name)
+    }
+}
+
+// Close sends the queued entries and closes the connection.
+func (s *remoteSink) Close() error {
+    s.mutex.Lock()
+    if s.closed {
+        s.mutex.Unlock()
+        return nil
+    }
+    s.closed = true
+    close(s.queue)
+    s.mutex.Unlock()
+    <-s.done
+    return nil
+}
+
+// remoteCore encodes the entries for a remoteSink.
+type remoteCore struct {
+    zapcore.LevelEnabler
+    enc  zapcore.Encoder
+    sink *remoteSink
+}
+
+func (c *remoteCore) With(fields []zapcore.Field) zapcore.Core {
+    clone := c.enc.Clone()
+    for _, f := range fields {
+        f.AddTo(clone)
+    }
+    return &remoteCore{c.LevelEnabler, clone, c.sink}
+}
+
+func (c *remoteCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+    if c.Enabled(ent.Level) {
+        return ce.AddCore(ent, c)
+    }
+    return ce
+}
+
+func (c *remoteCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+    buf, err := c.enc.EncodeEntry(ent, fields)
+    if err != nil {
+        return err
+    }
+    line := append([]byte(nil), bytes.TrimRight(buf.Bytes(), "\n")...)
+    buf.Free()
+    c.sink.enqueue(remoteEntry{level: ent.Level, time: ent.Time, line: line})
+    // like zap, flush before panicking or exiting
+    if ent.Level > zapcore.ErrorLevel {
+        return c.sink.Sync()
+    }
+    return nil
+}
+
+func (c *remoteCore) Sync() error {
+    return c.sink.Sync()
+}
+
+// connTransport writes the entries on a connection dialed on demand.
+type connTransport struct {
+    network string
+    addr    string
+    timeout time.Duration
+    encode  func(remoteEntry) []byte
+    conn    net.Conn
+}
+
+func (t *connTransport) send(entries []remoteEntry) (int, error) {
+    for i, e := range entries {
+        if t.conn == nil {
+            conn, err := net.DialTimeout(t.network, t.addr, t.timeout)
+            if err != nil {
+                return i, err
+            }
+            t.conn = conn
+        }
+        t.conn.SetWriteDeadline(time.Now().Add(t.timeout))
+        if _, err := t.conn.Write(t.encode(e)); err != nil {
+            t.close()
+            return i, err
+        }
+    }
+    return len(entries), nil
+}
+
+func (t *connTransport) close() error {
+    if t.conn == nil {
+        return nil
+    }
+    err := t.conn.Close()
+    t.conn = nil
+    return err
+}
+
+// syslogEncoder formats RFC5424 messages, framed by octet counting (RFC6587) over tcp.
+func syslogEncoder(rc RemoteConfig, hostname string) func(remoteEntry) []byte {
+    if hostname == "" {
+        hostname = "-"
+    }
+    header := " " + hostname + " " + rc.AppName + " " + strconv.Itoa(os.Getpid()) + " - - "
+    stream := rc.Network == "tcp"
+    return func(e remoteEntry) []byte {
+        pri := rc.Facility*8 + syslogSeverity(e.level)
+        msg := "<" + strconv.Itoa(pri) + ">1 " + e.time.Format("2006-01-02T15:04:05.000000Z07:00") + header + string(e.line)
+        if stream {
+            msg = strconv.Itoa(len(msg)) + " " + msg
+        }
+        return []byte(msg)
+    }
+}
+
+func syslogSeverity(level zapcore.Level) int {
+    switch level {
+    case zapcore.DebugLevel:
+        return 7
+    case zapcore.InfoLevel:
+        return 6
+    case zapcore.WarnLevel:
+        return 4
+    case zapcore.ErrorLevel:
+        return 3
+    }
+    // critical
+    return 2
+}
+
+// httpTransport posts the batches to Addr.
+type httpTransport struct {
+    rc     RemoteConfig
+    client *http.Client
+}
+
+func (t *httpTransport) send(entries []remoteEntry) (int, error) {
+    var body bytes.Buffer
+    contentType := "application/json"
+    switch t.rc.Format {
+    case "loki":
+        values := make([][2]string, len(entries))
+        for i, e := range entries {
+            values[i] = [2]string{strconv.FormatInt(e.time.UnixNano(), 10), string(e.line)}
+        }
+        stream := map[string]interface{}{"stream": t.rc.Labels, "values": values}
+        if err := json.NewEncoder(&body).Encode(map[string]interface{}{"streams": []interface{}{stream}}); err != nil {
+            return 0, err
+        }
+    case "elasticsearch":
+        contentType = "application/x-ndjson"
+        action := []byte(`{"index":{}}`)
+        if t.rc.Index != "" {
+            action, _ = json.Marshal(map[string]map[string]string{"index": {"_index": t.rc.Index}})
+        }
+        for _, e := range entries {
+            body.Write(action)
+            body.WriteByte('\n')
+            body.Write(e.line)
+            body.WriteByte('\n')
+        }
+    default:
+        body.WriteByte('[')
+        for i, e := range entries {
+            if i > 0 {
+                body.WriteByte(',')
+            }
+            body.Write(e.line)
+        }
+        body.WriteByte(']')
+    }
+
+    req, err := http.NewRequest(http.MethodPost, t.rc.Addr, &body)
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Content-Type", contentType)
+    for k, v := range t.rc.Headers {
+        req.Header.Set(k, v)
+    }
+    resp, err := t.client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    reply, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+    if resp.StatusCode >= 300 {
+        return 0, fmt.Errorf("http status %d: %s", resp.StatusCode, bytes.TrimSpace(reply))
+    }
+    if t.rc.Format == "elasticsearch" {
+        // the bulk api answers 200 even when documents are rejected
+        var bulk struct {
+            Errors bool `json:"errors"`
+        }
+        if json.Unmarshal(reply, &bulk) == nil && bulk.Errors {
+            return 0, errors.New("elasticsearch bulk reported errors")
+        }
+    }
+    return len(entries), nil
+}
+
+func (t *httpTransport) close() error {
+    t.client.CloseIdleConnections()
+    return nil
+}
@@ -0,0 +1,491 @@
+package MiaLog
+
+import (
+    "bufio"
+    "encoding/json"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+)
+
+type droppedKey struct {
+    sink, reason string
+}
+
+// testMetrics records the dropped entries, it is installed by recordDropped.
+type testMetrics struct {
+    mutex   sync.Mutex
+    dropped map[droppedKey]int
+}
+
+func (m *testMetrics) IncDropped(sink, reason string, n int) {
+    m.mutex.Lock()
+    m.dropped[droppedKey{sink, reason}] += n
+    m.mutex.Unlock()
+}
+
+func (m *testMetrics) IncPanic(function string) {}
+
+func (m *testMetrics) count(sink, reason string) int {
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+    return m.dropped[droppedKey{sink, reason}]
+}
+
+func recordDropped(t *testing.T) *testMetrics {
+    m := &testMetrics{dropped: make(map[droppedKey]int)}
+    SetMetrics(m)
+    t.Cleanup(func() { SetMetrics(nil) })
+    return m
+}
+
+// newTestSink starts a sink of rc for the service "game" and a logger writing to it.
+func newTestSink(t *testing.T, rc RemoteConfig) (*remoteSink, *zap.Logger) {
+    rc.setDefaults("game")
+    s, err := newRemoteSink(rc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { s.Close() })
+    return s, zap.New(&remoteCore{zapcore.DebugLevel, getEncoder(rc.Encoding, false), s})
+}
+
+func entryMsg(t *testing.T, line string) string {
+    var entry map[string]interface{}
+    if err := json.Unmarshal([]byte(line), &entry); err != nil {
+        t.Fatalf("entry %q is not json: %v", line, err)
+    }
+    msg, _ := entry["msg"].(string)
+    return msg
+}
+
+var syslogHeader = regexp.MustCompile(`^<(\d+)>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) \S+ game (\d+) - - (.*)$`)
+
+// checkSyslog checks the RFC5424 header of msg and returns its priority and json entry.
+func checkSyslog(t *testing.T, msg string) (int, string) {
+    m := syslogHeader.FindStringSubmatch(msg)
+    if m == nil {
+        t.Fatalf("not an RFC5424 message: %q", msg)
+    }
+    if m[3] != strconv.Itoa(os.Getpid()) {
+        t.Errorf("procid %s, want %d", m[3], os.Getpid())
+    }
+    pri, _ := strconv.Atoi(m[1])
+    return pri, m[4]
+}
+
+func TestRemoteSyslogUDP(t *testing.T) {
+    pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer pc.Close()
+
+    s, log := newTestSink(t, RemoteConfig{Type: "syslog", Addr: pc.LocalAddr().String()})
+    log.Info("hello")
+    log.Error("broken")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    // local0: info is 16*8+6, error is 16*8+3
+    want := []struct {
+        pri int
+        msg string
+    }{{134, "hello"}, {131, "broken"}}
+    buf := make([]byte, 64*1024)
+    for _, w := range want {
+        pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+        n, _, err := pc.ReadFrom(buf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        pri, line := checkSyslog(t, string(buf[:n]))
+        if pri != w.pri || entryMsg(t, line) != w.msg {
+            t.Errorf("got <%d> %s, want <%d> %s", pri, line, w.pri, w.msg)
+        }
+    }
+}
+
+func TestRemoteSyslogTCP(t *testing.T) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+    received := make(chan []string, 1)
+    go func() {
+        conn, err := ln.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+        r := bufio.NewReader(conn)
+        var msgs []string
+        for len(msgs) < 2 {
+            // octet counting: "<length> <message>"
+            size, err := r.ReadString(' ')
+            if err != nil {
+                break
+            }
+            n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+            if err != nil {
+                break
+            }
+            msg := make([]byte, n)
+            if _, err := io.ReadFull(r, msg); err != nil {
+                break
+            }
+            msgs = append(msgs, string(msg))
+        }
+        received <- msgs
+    }()
+
+    s, log := newTestSink(t, RemoteConfig{Type: "syslog", Network: "tcp", Addr: ln.Addr().String(), Facility: 1})
+    log.Warn("first")
+    log.Debug("second")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    select {
+    case msgs := <-received:
+        if len(msgs) != 2 {
+            t.Fatalf("got %d framed messages, want 2: %q", len(msgs), msgs)
+        }
+        if pri, line := checkSyslog(t, msgs[0]); pri != 12 || entryMsg(t, line) != "first" {
+            t.Errorf("got <%d> %s, want <12> first", pri, line)
+        }
+        if pri, line := checkSyslog(t, msgs[1]); pri != 15 || entryMsg(t, line) != "second" {
+            t.Errorf("got <%d> %s, want <15> second", pri, line)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("timeout waiting for the syslog messages")
+    }
+}
+
+func TestRemoteUnix(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "log.sock")
+    ln, err := net.Listen("unix", path)
+    if err != nil {
+        t.Skip("unix sockets unavailable:", err)
+    }
+    defer ln.Close()
+    received := make(chan []string, 1)
+    go func() {
+        conn, err := ln.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+        sc := bufio.NewScanner(conn)
+        var lines []string
+        for len(lines) < 2 && sc.Scan() {
+            lines = append(lines, sc.Text())
+        }
+        received <- lines
+    }()
+
+    s, log := newTestSink(t, RemoteConfig{Type: "unix", Addr: path})
+    log.Info("one")
+    log.Info("two")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    select {
+    case lines := <-received:
+        if len(lines) != 2 || entryMsg(t, lines[0]) != "one" || entryMsg(t, lines[1]) != "two" {
+            t.Errorf("got lines %q", lines)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("timeout waiting for the unix socket lines")
+    }
+}
+
+func TestRemoteUnixgram(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "log.sock")
+    pc, err := net.ListenPacket("unixgram", path)
+    if err != nil {
+        t.Skip("unixgram sockets unavailable:", err)
+    }
+    defer pc.Close()
+
+    s, log := newTestSink(t, RemoteConfig{Type: "unix", Network: "unixgram", Addr: path})
+    log.Info("datagram")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    buf := make([]byte, 64*1024)
+    pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+    n, _, err := pc.ReadFrom(buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // a datagram is a whole entry, without the newline of the stream socket
+    if line := string(buf[:n]); strings.HasSuffix(line, "\n") || entryMsg(t, line) != "datagram" {
+        t.Errorf("got datagram %q", line)
+    }
+}
+
+// httpRequest is a request received by the stand-in server.
+type httpRequest struct {
+    header http.Header
+    body   []byte
+}
+
+// newHTTPServer records the requests and answers with reply.
+func newHTTPServer(t *testing.T, status int, reply string) (*httptest.Server, chan httpRequest) {
+    requests := make(chan httpRequest, 16)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := ioutil.ReadAll(r.Body)
+        requests <- httpRequest{r.Header, body}
+        w.WriteHeader(status)
+        io.WriteString(w, reply)
+    }))
+    t.Cleanup(srv.Close)
+    return srv, requests
+}
+
+func nextRequest(t *testing.T, requests chan httpRequest) httpRequest {
+    select {
+    case req := <-requests:
+        return req
+    case <-time.After(5 * time.Second):
+        t.Fatal("timeout waiting for the http batch")
+    }
+    return httpRequest{}
+}
+
+func TestRemoteHTTPJSON(t *testing.T) {
+    srv, requests := newHTTPServer(t, http.StatusOK, "")
+    s, log := newTestSink(t, RemoteConfig{
+        Type:    "http",
+        Addr:    srv.URL,
+        Headers: map[string]string{"Authorization": "Bearer token"},
+    })
+    log.Info("a")
+    log.Warn("b")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    req := nextRequest(t, requests)
+    if ct := req.header.Get("Content-Type"); ct != "application/json" {
+        t.Errorf("Content-Type %q", ct)
+    }
+    if auth := req.header.Get("Authorization"); auth != "Bearer token" {
+        t.Errorf("Authorization %q", auth)
+    }
+    var batch []map[string]interface{}
+    if err := json.Unmarshal(req.body, &batch); err != nil {
+        t.Fatalf("body %s is not a json array: %v", req.body, err)
+    }
+    if len(batch) != 2 || batch[0]["msg"] != "a" || batch[1]["msg"] != "b" || batch[1]["level"] != "WARN" {
+        t.Errorf("got batch %s", req.body)
+    }
+}
+
+func TestRemoteHTTPLoki(t *testing.T) {
+    srv, requests := newHTTPServer(t, http.StatusNoContent, "")
+    s, log := newTestSink(t, RemoteConfig{Type: "http", Format: "loki", Addr: srv.URL})
+    before := time.Now()
+    log.Info("pushed")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    req := nextRequest(t, requests)
+    var push struct {
+        Streams []struct {
+            Stream map[string]string `json:"stream"`
+            Values [][2]string       `json:"values"`
+        } `json:"streams"`
+    }
+    if err := json.Unmarshal(req.body, &push); err != nil {
+        t.Fatalf("body %s: %v", req.body, err)
+    }
+    if len(push.Streams) != 1 || len(push.Streams[0].Values) != 1 {
+        t.Fatalf("got push %s", req.body)
+    }
+    stream := push.Streams[0]
+    if stream.Stream[ServiceKey] != "game" {
+        t.Errorf("labels %v, want the service", stream.Stream)
+    }
+    ns, err := strconv.ParseInt(stream.Values[0][0], 10, 64)
+    if err != nil || ns < before.UnixNano() {
+        t.Errorf("timestamp %q is not the entry time in nanoseconds", stream.Values[0][0])
+    }
+    if entryMsg(t, stream.Values[0][1]) != "pushed" {
+        t.Errorf("line %s", stream.Values[0][1])
+    }
+}
+
+func TestRemoteHTTPElasticsearch(t *testing.T) {
+    srv, requests := newHTTPServer(t, http.StatusOK, `{"errors":false}`)
+    s, log := newTestSink(t, RemoteConfig{Type: "http", Format: "elasticsearch", Index: "logs-game", Addr: srv.URL})
+    log.Info("doc1")
+    log.Info("doc2")
+    if err := s.Sync(); err != nil {
+        t.Fatal(err)
+    }
+
+    req := nextRequest(t, requests)
+    if ct := req.header.Get("Content-Type"); ct != "application/x-ndjson" {
+        t.Errorf("Content-Type %q", ct)
+    }
+    lines := strings.Split(strings.TrimSuffix(string(req.body), "\n"), "\n")
+    if len(lines) != 4 {
+        t.Fatalf("got %d bulk lines, want 4: %s", len(lines), req.body)
+    }
+    for i, msg := range []string{"doc1", "doc2"} {
+        if lines[2*i] != `{"index":{"_index":"logs-game"}}` {
+            t.Errorf("action %s", lines[2*i])
+        }
+        if entryMsg(t, lines[2*i+1]) != msg {
+            t.Errorf("document %s, want %s", lines[2*i+1], msg)
+        }
+    }
+}
+
+func TestRemoteSendErrors(t *testing.T) {
+    m := recordDropped(t)
+    srv, requests := newHTTPServer(t, http.StatusOK, `{"errors":true}`)
+    s, log := newTestSink(t, RemoteConfig{Name: "es", Type: "http", Format: "elasticsearch", Addr: srv.URL})
+    log.Info("rejected")
+    log.Info("rejected too")
+    s.Sync()
+    nextRequest(t, requests)
+    if n := m.count("es", "send error"); n != 2 {
+        t.Errorf("dropped %d entries on a bulk error, want 2", n)
+    }
+
+    failing, _ := newHTTPServer(t, http.StatusInternalServerError, "down")
+    s, log = newTestSink(t, RemoteConfig{Name: "json", Type: "http", Addr: failing.URL})
+    log.Info("lost")
+    s.Sync()
+    if n := m.count("json", "send error"); n != 1 {
+        t.Errorf("dropped %d entries on status 500, want 1", n)
+    }
+}
+
+// stalledServer holds every request until release is closed,
+// started is signalled when the first one arrives.
+func stalledServer(t *testing.T) (srv *httptest.Server, started, release chan struct{}, received *entryCounter) {
+    started = make(chan struct{})
+    release = make(chan struct{})
+    received = &entryCounter{}
+    var once sync.Once
+    srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var batch []json.RawMessage
+        body, _ := ioutil.ReadAll(r.Body)
+        json.Unmarshal(body, &batch)
+        received.add(len(batch))
+        once.Do(func() { close(started) })
+        <-release
+    }))
+    t.Cleanup(srv.Close)
+    return
+}
+
+type entryCounter struct {
+    mutex sync.Mutex
+    n     int
+}
+
+func (c *entryCounter) add(n int) {
+    c.mutex.Lock()
+    c.n += n
+    c.mutex.Unlock()
+}
+
+func (c *entryCounter) get() int {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    return c.n
+}
+
+func TestRemoteDropPolicy(t *testing.T) {
+    m := recordDropped(t)
+    srv, started, release, received := stalledServer(t)
+    s, log := newTestSink(t, RemoteConfig{Name: "slow", Type: "http", Addr: srv.URL, BatchSize: 1, BufferSize: 2})
+
+    // the first entry is being sent and blocks the sink
+    log.Info("in flight")
+    select {
+    case <-started:
+    case <-time.After(5 * time.Second):
+        t.Fatal("timeout waiting for the first batch")
+    }
+    // two fill the buffer, the next three are dropped
+    for i := 0; i < 5; i++ {
+        log.Info("queued " + strconv.Itoa(i))
+    }
+    if n := m.count("slow", "buffer full"); n != 3 {
+        t.Errorf("dropped %d entries with a full buffer, want 3", n)
+    }
+
+    close(release)
+    s.Close()
+    if n := received.get(); n != 3 {
+        t.Errorf("server received %d entries, want 3", n)
+    }
+    log.Info("after close")
+    if n := m.count("slow", "closed"); n != 1 {
+        t.Errorf("dropped %d entries after Close, want 1", n)
+    }
+}
+
+func TestRemoteBlockPolicy(t *testing.T) {
+    m := recordDropped(t)
+    srv, started, release, received := stalledServer(t)
+    s, log := newTestSink(t, RemoteConfig{Name: "blocking", Type: "http", Addr: srv.URL, BatchSize: 1, BufferSize: 1, Policy: "block"})
+
+    log.Info("in flight")
+    select {
+    case <-started:
+    case <-time.After(5 * time.Second):
+        t.Fatal("timeout waiting for the first batch")
+    }
+    log.Info("fills the buffer")
+    returned := make(chan struct{})
+    go func() {
+        log.Info("waits for room")
+        close(returned)
+    }()
+    select {
+    case <-returned:
+        t.Fatal("the caller was not blocked by the full buffer")
+    case <-time.After(100 * time.Millisecond):
+    }
+
+    close(release)
+    select {
+    case <-returned:
+    case <-time.After(5 * time.Second):
+        t.Fatal("the caller stayed blocked after the sink caught up")
+    }
+    s.Close()
+    if n := received.get(); n != 3 {
+        t.Errorf("server received %d entries, want 3", n)
+    }
+    if n := m.count("blocking", "buffer full"); n != 0 {
+        t.Errorf("dropped %d entries with the block policy", n)
+    }
+}
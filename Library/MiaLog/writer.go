@@ -0,0 +1,132 @@
+package MiaLog
+
+import (
+    "compress/gzip"
+    "io"
+    "math"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+)
+
+var strftimeVerb = regexp.MustCompile(`%[%+A-Za-z]`)
+
+// newRotateWriter returns a writer to filename rotated per rc,
+// filename itself is a link to the current file.
+func newRotateWriter(filename string, rc RotationConfig) (*rotatelogs.RotateLogs, error) {
+    pattern := strings.TrimSuffix(filename, ".log") + rc.Pattern + ".log"
+    options := []rotatelogs.Option{
+        rotatelogs.WithLinkName(filename),
+        rotatelogs.WithRotationTime(rc.RotationTime),
+    }
+    // rotatelogs only cleans uncompressed files and refuses age and count together,
+    // retention of everything else is done by cleanRotated
+    if rc.MaxAge > 0 {
+        options = append(options, rotatelogs.WithMaxAge(rc.MaxAge))
+    } else if rc.MaxBackups <= 0 {
+        // rotatelogs falls back to 7 days without age and count
+        options = append(options, rotatelogs.WithRotationCount(math.MaxUint32))
+    } else {
+        options = append(options, rotatelogs.WithRotationCount(uint(rc.MaxBackups)))
+    }
+    if rc.MaxSize > 0 {
+        options = append(options, rotatelogs.WithRotationSize(rc.MaxSize*1024*1024))
+    }
+    glob := strftimeVerb.ReplaceAllString(pattern, "*") + "*"
+    // events are delivered on new goroutines, one at a time keeps compress and clean apart
+    var mutex sync.Mutex
+    options = append(options, rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+        if e.Type() != rotatelogs.FileRotatedEventType {
+            return
+        }
+        mutex.Lock()
+        defer mutex.Unlock()
+        ev := e.(*rotatelogs.FileRotatedEvent)
+        if rc.Compress && ev.PreviousFile() != "" {
+            if err := compressFile(ev.PreviousFile()); err != nil {
+                os.Stderr.WriteString("MiaLog: compress " + ev.PreviousFile() + ": " + err.Error() + "\n")
+            }
+        }
+        cleanRotated(glob, filename, ev.CurrentFile(), rc)
+    })))
+    return rotatelogs.New(pattern, options...)
+}
+
+// compressFile gzips name into name.gz and removes name.
+func compressFile(name string) error {
+    in, err := os.Open(name)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+    if err != nil {
+        return err
+    }
+    zw := gzip.NewWriter(out)
+    if _, err = io.Copy(zw, in); err == nil {
+        err = zw.Close()
+    }
+    if closeErr := out.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(name + ".gz")
+        return err
+    }
+    return os.Remove(name)
+}
+
+// cleanRotated applies MaxAge and MaxBackups to the rotated files matching glob,
+// compressed and generational (".1", ".2"...) ones included. Handlers run
+// asynchronously, so the file behind link is kept even if it rotated since.
+func cleanRotated(glob, link, current string, rc RotationConfig) {
+    matches, err := filepath.Glob(glob)
+    if err != nil {
+        return
+    }
+    if target, err := filepath.EvalSymlinks(link); err == nil {
+        link = target
+    }
+    type rotated struct {
+        name    string
+        modTime time.Time
+    }
+    files := make([]rotated, 0, len(matches))
+    for _, name := range matches {
+        if name == current || sameFile(name, link) || strings.HasSuffix(name, "_lock") || strings.HasSuffix(name, "_symlink") {
+            continue
+        }
+        fi, err := os.Lstat(name)
+        if err != nil || !fi.Mode().IsRegular() {
+            continue
+        }
+        files = append(files, rotated{name, fi.ModTime()})
+    }
+    sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+    cutoff := time.Now().Add(-rc.MaxAge)
+    for i, f := range files {
+        if (rc.MaxBackups > 0 && i >= rc.MaxBackups) || (rc.MaxAge > 0 && f.modTime.Before(cutoff)) {
+            os.Remove(f.name)
+        }
+    }
+}
+
+func sameFile(a, b string) bool {
+    fa, err := os.Stat(a)
+    if err != nil {
+        return false
+    }
+    fb, err := os.Stat(b)
+    if err != nil {
+        return false
+    }
+    return os.SameFile(fa, fb)
+}
@@ -0,0 +1,44 @@
+package MiaPrometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LogCollector records the instrumentation of MiaLog, pass it to MiaLog.SetMetrics.
+type LogCollector struct {
+	dropped *prometheus.CounterVec
+	panics  *prometheus.CounterVec
+}
+
+// NewLogCollector creates the log metrics and registers them on p.
+func NewLogCollector(serviceName string, p prom) *LogCollector {
+	c := &LogCollector{
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        logDroppedName,
+			Help:        "How many log entries a remote sink lost, reason is buffer full, send error or closed.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"sink", "reason"},
+		),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        logPanicsName,
+			Help:        "How many panics MiaLog recovered, partitioned by the function which panicked.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"function"},
+		),
+	}
+	p.RegisterCounter(c.dropped)
+	p.RegisterCounter(c.panics)
+	return c
+}
+
+// IncDropped .
+func (c *LogCollector) IncDropped(sink, reason string, n int) {
+	c.dropped.WithLabelValues(sink, reason).Add(float64(n))
+}
+
+// IncPanic .
+func (c *LogCollector) IncPanic(function string) {
+	c.panics.WithLabelValues(function).Inc()
+}
@@ -27,6 +27,16 @@ const (
 
 	kafkaProducerReqsName    = "kafka_producer_requests_total"
 	kafkaProducerLatencyName = "kafka_producer_duration_seconds"
+
+	redisLatencyName     = "redis_command_duration_seconds"
+	redisErrorsName      = "redis_command_errors_total"
+	redisPoolConnsName   = "redis_pool_connections"
+	redisPoolWaitName    = "redis_pool_wait_duration_seconds"
+	redisConnCreatedName = "redis_pool_conn_created_total"
+	redisReconnectsName  = "redis_reconnects_total"
+
+	logDroppedName = "log_dropped_entries_total"
+	logPanicsName  = "recovered_panics_total"
 )
 
 // Prometheus is a handler that exposes prometheus metrics for the number of requests,
@@ -42,6 +52,7 @@ type Prometheus struct {
 	ormLatency *prometheus.HistogramVec
 	counters   []*prometheus.CounterVec
 	histograms []*prometheus.HistogramVec
+	gauges     []*prometheus.GaugeVec
 }
 
 type log interface {
@@ -100,6 +111,9 @@ func registerPrometheus(p *Prometheus, name, listen string) {
 	for i := 0; i < len(p.histograms); i++ {
 		prometheus.MustRegister(p.histograms[i])
 	}
+	for i := 0; i < len(p.gauges); i++ {
+		prometheus.MustRegister(p.gauges[i])
+	}
 	return
 }
 
@@ -159,4 +173,9 @@ func (p *Prometheus) RegisterCounter(conter *prometheus.CounterVec) {
 // RegisterHistogram .
 func (p *Prometheus) RegisterHistogram(histogram *prometheus.HistogramVec) {
 	p.histograms = append(p.histograms, histogram)
+}
+
+// RegisterGauge .
+func (p *Prometheus) RegisterGauge(gauge *prometheus.GaugeVec) {
+	p.gauges = append(p.gauges, gauge)
 }
\ No newline at end of file
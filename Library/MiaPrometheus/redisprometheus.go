@@ -0,0 +1,109 @@
+package MiaPrometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type gaugeProm interface {
+	prom
+	RegisterGauge(gauge *prometheus.GaugeVec)
+}
+
+// RedisCollector records the instrumentation of DB.RadixDriver,
+// pass it to RadixDriver.SetMetrics.
+type RedisCollector struct {
+	latency     *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	poolConns   *prometheus.GaugeVec
+	poolWait    *prometheus.HistogramVec
+	connCreated *prometheus.CounterVec
+	reconnects  *prometheus.CounterVec
+}
+
+// NewRedisCollector creates the redis metrics and registers them on p.
+func NewRedisCollector(serviceName string, p gaugeProm) *RedisCollector {
+	c := &RedisCollector{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        redisLatencyName,
+			Help:        "How long redis commands took, partitioned by server and command.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+			[]string{"addr", "command"},
+		),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        redisErrorsName,
+			Help:        "How many redis commands failed, partitioned by server and command.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"addr", "command"},
+		),
+		poolConns: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        redisPoolConnsName,
+			Help:        "Redis pool connections, state is size or available.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"addr", "state"},
+		),
+		poolWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        redisPoolWaitName,
+			Help:        "How long redis commands waited for a pooled connection.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+			Buckets:     prometheus.ExponentialBuckets(0.0001, 2, 15),
+		},
+			[]string{"addr"},
+		),
+		connCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        redisConnCreatedName,
+			Help:        "How many redis connections the pool dialed, reason \"pool empty\" means no connection was idle after a wait.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"addr", "reason"},
+		),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        redisReconnectsName,
+			Help:        "How many times the redis pool was rebuilt after a failed ping.",
+			ConstLabels: prometheus.Labels{"service": serviceName},
+		},
+			[]string{"addr"},
+		),
+	}
+	p.RegisterHistogram(c.latency)
+	p.RegisterCounter(c.errors)
+	p.RegisterGauge(c.poolConns)
+	p.RegisterHistogram(c.poolWait)
+	p.RegisterCounter(c.connCreated)
+	p.RegisterCounter(c.reconnects)
+	return c
+}
+
+// ObserveCommand .
+func (c *RedisCollector) ObserveCommand(addr, command string, elapsed time.Duration, err error) {
+	c.latency.WithLabelValues(addr, command).Observe(elapsed.Seconds())
+	if err != nil {
+		c.errors.WithLabelValues(addr, command).Inc()
+	}
+}
+
+// ObservePool .
+func (c *RedisCollector) ObservePool(addr string, size, available int) {
+	c.poolConns.WithLabelValues(addr, "size").Set(float64(size))
+	c.poolConns.WithLabelValues(addr, "available").Set(float64(available))
+}
+
+// ObservePoolWait .
+func (c *RedisCollector) ObservePoolWait(addr string, wait time.Duration) {
+	c.poolWait.WithLabelValues(addr).Observe(wait.Seconds())
+}
+
+// IncConnCreated .
+func (c *RedisCollector) IncConnCreated(addr, reason string) {
+	c.connCreated.WithLabelValues(addr, reason).Inc()
+}
+
+// IncReconnect .
+func (c *RedisCollector) IncReconnect(addr string) {
+	c.reconnects.WithLabelValues(addr).Inc()
+}
@@ -0,0 +1,60 @@
+package MiaSession
+
+import (
+	"MiaGame/Library/MiaLog"
+	"context"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+)
+
+type contextKey struct{}
+
+// irisValuesKey is the iris.Context values key of the session.
+const irisValuesKey = "MiaSession"
+
+// Handler is a net/http middleware starting the session of every request,
+// handlers get it with FromContext and it is committed once they return.
+func (m *Manager) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := m.Start(w, r)
+		if err != nil {
+			MiaLog.CError("session: start error:", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, s)))
+		if err := s.Commit(); err != nil {
+			MiaLog.CError("session: commit error:", err)
+		}
+	})
+}
+
+// FromContext returns the session started by Handler, nil outside of it.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
+
+// IrisHandler is the iris middleware equivalent of Handler, handlers get the session with Get.
+func (m *Manager) IrisHandler() iris.Handler {
+	return func(ctx iris.Context) {
+		s, err := m.Start(ctx.ResponseWriter(), ctx.Request())
+		if err != nil {
+			MiaLog.CError("session: start error:", err)
+			ctx.StopWithStatus(http.StatusInternalServerError)
+			return
+		}
+		ctx.Values().Set(irisValuesKey, s)
+		ctx.Next()
+		if err := s.Commit(); err != nil {
+			MiaLog.CError("session: commit error:", err)
+		}
+	}
+}
+
+// Get returns the session started by IrisHandler, nil outside of it.
+func Get(ctx iris.Context) *Session {
+	s, _ := ctx.Values().Get(irisValuesKey).(*Session)
+	return s
+}
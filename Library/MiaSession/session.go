@@ -0,0 +1,381 @@
+package MiaSession
+
+import (
+	"MiaGame/Library/DB"
+	"MiaGame/Library/MiaCrypt"
+	"MiaGame/Library/MiaLog"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	flashPrefix  = "_flash_"
+	createdField = "_created"
+)
+
+// ErrNoSigningKey is returned by NewManager when no cookie signing key is configured.
+var ErrNoSigningKey = errors.New("session: at least one signing key is required")
+
+// Config the session cookie and storage configuration
+type Config struct {
+	// CookieName defaults to "MIASESSID".
+	CookieName string `yaml:"cookiename"`
+	// Keys sign the session cookie with HMAC-SHA256. Keys[0] signs new cookies,
+	// every key is accepted when verifying, so a key can be rotated by
+	// prepending the new one and dropping the old one later.
+	Keys []string `yaml:"keys"`
+	// KeyRing signs the cookies with its primary key instead of Keys[0], the cookie
+	// names the key so rotation and retirement follow the ring. Cookies signed
+	// with Keys are still accepted.
+	KeyRing *MiaCrypt.KeyRing `yaml:"-"`
+	// Expires is the idle lifetime of a session, renewed on every request. Defaults to 30 minutes.
+	Expires time.Duration `yaml:"expires"`
+	// KeyPrefix of the redis hashes holding the sessions. Defaults to "session:".
+	KeyPrefix string `yaml:"keyprefix"`
+
+	Path     string        `yaml:"path"` // defaults to "/"
+	Domain   string        `yaml:"domain"`
+	Secure   bool          `yaml:"secure"`
+	HttpOnly bool          `yaml:"httponly"`
+	SameSite http.SameSite `yaml:"samesite"`
+}
+
+// Manager creates and loads sessions stored in redis hashes.
+type Manager struct {
+	r   *DB.RadixDriver
+	cfg Config
+}
+
+// NewManager returns a manager storing sessions in r.
+func NewManager(r *DB.RadixDriver, cfg Config) (*Manager, error) {
+	if cfg.KeyRing == nil && (len(cfg.Keys) == 0 || cfg.Keys[0] == "") {
+		return nil, ErrNoSigningKey
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "MIASESSID"
+	}
+	if cfg.Expires <= 0 {
+		cfg.Expires = 30 * time.Minute
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "session:"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	return &Manager{r: r, cfg: cfg}, nil
+}
+
+// Session is the server-side state of one client. Changes made with Set,
+// Delete and flashes are buffered until Commit, which the middlewares call
+// after the handler returned. A Session is safe for concurrent use.
+type Session struct {
+	m         *Manager
+	mutex     sync.Mutex
+	id        string
+	isNew     bool
+	destroyed bool
+	values    map[string]string
+	changed   map[string]bool // field -> true when set, false when deleted
+}
+
+// Start loads the session of the request, or creates a new one and sets its cookie on w.
+// The idle lifetime of an existing session is renewed.
+func (m *Manager) Start(w http.ResponseWriter, req *http.Request) (*Session, error) {
+	if cookie, err := req.Cookie(m.cfg.CookieName); err == nil {
+		if id, ok := m.verify(cookie.Value); ok {
+			s, err := m.load(id)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				return s, nil
+			}
+		}
+	}
+	return m.create(w)
+}
+
+func (m *Manager) create(w http.ResponseWriter) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		m:       m,
+		id:      id,
+		isNew:   true,
+		values:  make(map[string]string),
+		changed: map[string]bool{createdField: true},
+	}
+	s.values[createdField] = strconv.FormatInt(time.Now().Unix(), 10)
+	m.setCookie(w, id)
+	return s, nil
+}
+
+func (m *Manager) load(id string) (*Session, error) {
+	values := make(map[string]string)
+	b := m.r.NewBatch()
+	b.HGetAll(m.redisKey(id), &values)
+	// a no-op when the key is missing
+	b.Expire(m.redisKey(id), int(m.ttl()))
+	if _, err := b.Exec(); err != nil {
+		return nil, err
+	}
+	// expired or destroyed
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return &Session{m: m, id: id, values: values, changed: make(map[string]bool)}, nil
+}
+
+// redisKey is the hash key for raw commands and the unprefixed Batch helpers.
+func (m *Manager) redisKey(id string) string {
+	return m.r.Config.Prefix + m.cfg.KeyPrefix + id
+}
+
+func (m *Manager) ttl() int64 {
+	return int64(m.cfg.Expires / time.Second)
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    m.signedID(id),
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		Secure:   m.cfg.Secure,
+		HttpOnly: m.cfg.HttpOnly,
+		SameSite: m.cfg.SameSite,
+	})
+}
+
+func (m *Manager) removeCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    "",
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		Secure:   m.cfg.Secure,
+		HttpOnly: m.cfg.HttpOnly,
+		SameSite: m.cfg.SameSite,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+}
+
+// signedID is the cookie value, "id.sig" or "id.kid.sig" with a key ring.
+func (m *Manager) signedID(id string) string {
+	if m.cfg.KeyRing != nil {
+		if kid, sum, err := m.cfg.KeyRing.Sign([]byte(id)); err == nil {
+			return id + "." + kid + "." + base64.RawURLEncoding.EncodeToString(sum)
+		} else if len(m.cfg.Keys) == 0 || m.cfg.Keys[0] == "" {
+			// no active key, the cookie won't verify and a new session starts every request
+			MiaLog.CError("session: sign cookie:", err)
+			return id + "."
+		}
+	}
+	return id + "." + m.sign(m.cfg.Keys[0], id)
+}
+
+func (m *Manager) sign(key, id string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the cookie signature against the key ring key it names, or every configured key.
+func (m *Manager) verify(value string) (string, bool) {
+	idx := strings.LastIndexByte(value, '.')
+	if idx <= 0 {
+		return "", false
+	}
+	id, sig := value[:idx], value[idx+1:]
+	if kidx := strings.LastIndexByte(id, '.'); kidx > 0 && m.cfg.KeyRing != nil {
+		sum, err := base64.RawURLEncoding.DecodeString(sig)
+		if err == nil && m.cfg.KeyRing.Verify(id[kidx+1:], []byte(id[:kidx]), sum) {
+			return id[:kidx], true
+		}
+		return "", false
+	}
+	for _, key := range m.cfg.Keys {
+		if key != "" && hmac.Equal([]byte(sig), []byte(m.sign(key, id))) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ID returns the session id.
+func (s *Session) ID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.id
+}
+
+// IsNew reports whether the session was created by this request.
+func (s *Session) IsNew() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.isNew
+}
+
+// Get returns the value of key, "" when unset.
+func (s *Session) Get(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.values[key]
+}
+
+// GetInt returns the value of key as an int, 0 when unset or not a number.
+func (s *Session) GetInt(key string) int {
+	n, _ := strconv.Atoi(s.Get(key))
+	return n
+}
+
+// Set stores value under key.
+func (s *Session) Set(key, value string) {
+	s.mutex.Lock()
+	s.values[key] = value
+	s.changed[key] = true
+	s.mutex.Unlock()
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mutex.Lock()
+	delete(s.values, key)
+	s.changed[key] = false
+	s.mutex.Unlock()
+}
+
+// SetFlash stores a message which is removed once read by GetFlash, typically on the next request.
+func (s *Session) SetFlash(key, value string) {
+	s.Set(flashPrefix+key, value)
+}
+
+// GetFlash returns and removes the flash message key.
+func (s *Session) GetFlash(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, ok := s.values[flashPrefix+key]
+	if ok {
+		delete(s.values, flashPrefix+key)
+		s.changed[flashPrefix+key] = false
+	}
+	return v, ok
+}
+
+// Save writes the exported fields of the struct pointed by info into the session hash
+// using the RadixDriver struct codec, it is written immediately.
+func (s *Session) Save(info interface{}) error {
+	if err := s.Commit(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := make(map[string]string)
+	b := s.m.r.NewBatch()
+	b.HSetStruct(s.m.redisKey(s.id), info)
+	b.HGetAll(s.m.redisKey(s.id), &values)
+	b.Expire(s.m.redisKey(s.id), int(s.m.ttl()))
+	if _, err := b.Exec(); err != nil {
+		return err
+	}
+	s.values = values
+	return nil
+}
+
+// Load reads the session hash into the struct pointed by info using the RadixDriver struct codec.
+func (s *Session) Load(info interface{}) error {
+	if err := s.Commit(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.m.r.LoadFromRedis(s.m.redisKey(s.id), info)
+}
+
+// Commit writes the buffered changes and renews the lifetime of the session.
+func (s *Session) Commit() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.destroyed || len(s.changed) == 0 {
+		return nil
+	}
+	key := s.m.redisKey(s.id)
+	b := s.m.r.NewBatch()
+	for field, set := range s.changed {
+		if set {
+			b.HSet(key, field, s.values[field])
+		} else {
+			b.Cmd(nil, DB.HDEL, key, field)
+		}
+	}
+	b.Expire(key, int(s.m.ttl()))
+	if _, err := b.ExecTx(); err != nil {
+		return err
+	}
+	s.changed = make(map[string]bool)
+	s.isNew = false
+	return nil
+}
+
+// Destroy deletes the session from redis and expires its cookie.
+func (s *Session) Destroy(w http.ResponseWriter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.destroyed = true
+	s.values = make(map[string]string)
+	s.changed = make(map[string]bool)
+	s.m.removeCookie(w)
+	b := s.m.r.NewBatch()
+	b.Cmd(nil, DB.DEL, s.m.redisKey(s.id))
+	_, err := b.Exec()
+	return err
+}
+
+// Regenerate moves the session to a new id and cookie, keeping its data.
+// Call it after a privilege change such as login to prevent session fixation.
+func (s *Session) Regenerate(w http.ResponseWriter) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.destroyed {
+		return errors.New("session: regenerate a destroyed session")
+	}
+	oldKey, newKey := s.m.redisKey(s.id), s.m.redisKey(id)
+	b := s.m.r.NewBatch()
+	b.Cmd(nil, DB.DEL, oldKey)
+	// the pending changes plus the persisted data are written under the new key
+	for field, value := range s.values {
+		b.HSet(newKey, field, value)
+	}
+	b.Expire(newKey, int(s.m.ttl()))
+	if _, err := b.ExecTx(); err != nil {
+		return err
+	}
+	s.id = id
+	s.changed = make(map[string]bool)
+	s.m.setCookie(w, id)
+	return nil
+}
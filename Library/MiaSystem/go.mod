@@ -3,9 +3,15 @@ module MiaGame/Library/MiaSystem
 go 1.17
 
 require (
+	MiaGame/Library/MiaLog v0.0.0
 	github.com/disintegration/imaging v1.6.2
 	github.com/gorilla/context v1.1.1
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 )
 
 require golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
+
+replace (
+	MiaGame/Library/MiaCrypt => ../MiaCrypt
+	MiaGame/Library/MiaLog => ../MiaLog
+)
@@ -1,6 +1,7 @@
 package MiaSystem
 
 import (
+	"MiaGame/Library/MiaLog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -8,11 +9,13 @@ import (
 
 type SignalQuitFunc func()
 
+// InitSignal blocks until a quit signal and calls fn,
+// SIGUSR1 enables the debug logs and SIGUSR2 restores the configured level.
 func InitSignal(fn SignalQuitFunc) {
 
 NEW_SIGNAL:
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT,syscall.SIGKILL)
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT,syscall.SIGKILL, syscall.SIGUSR1, syscall.SIGUSR2)
 	for {
 		select {
 		case sig := <-ch:
@@ -24,6 +27,10 @@ NEW_SIGNAL:
 					}
 					close(ch)
 					return
+				case syscall.SIGUSR1:
+					MiaLog.EnableDebug()
+				case syscall.SIGUSR2:
+					MiaLog.ResetLevel()
 				case syscall.SIGHUP:
 					close(ch)
 					goto NEW_SIGNAL
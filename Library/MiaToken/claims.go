@@ -0,0 +1,126 @@
+package MiaToken
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrMalformed    = errors.New("MiaToken: malformed token")
+	ErrAlgorithm    = errors.New("MiaToken: algorithm not allowed")
+	ErrSignature    = errors.New("MiaToken: invalid signature")
+	ErrExpired      = errors.New("MiaToken: token is expired")
+	ErrNotYetValid  = errors.New("MiaToken: token is not valid yet")
+	ErrIssuer       = errors.New("MiaToken: unexpected issuer")
+	ErrAudience     = errors.New("MiaToken: unexpected audience")
+	ErrRevoked      = errors.New("MiaToken: token is revoked")
+	ErrMissingClaim = errors.New("MiaToken: missing claim")
+)
+
+// Audience is the aud claim, a string or an array of strings in json.
+type Audience []string
+
+// MarshalJSON encodes a single audience as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether aud is in a.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the registered claims of RFC 7519, times in unix seconds, and
+// the private claims of the game in Extra.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	Extra map[string]interface{} `json:"-"`
+}
+
+type registeredClaims Claims
+
+var registeredNames = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+// Set sets a private claim.
+func (c *Claims) Set(name string, value interface{}) {
+	if c.Extra == nil {
+		c.Extra = make(map[string]interface{})
+	}
+	c.Extra[name] = value
+}
+
+// Get returns a private claim, numbers are float64 after parsing.
+func (c *Claims) Get(name string) interface{} {
+	return c.Extra[name]
+}
+
+// GetString returns a private claim if it is a string.
+func (c *Claims) GetString(name string) string {
+	s, _ := c.Extra[name].(string)
+	return s
+}
+
+// MarshalJSON merges Extra with the registered claims, which win.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(registeredClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return b, err
+	}
+	m := make(map[string]interface{}, len(c.Extra)+len(registeredNames))
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON fills the registered claims and puts the others in Extra.
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*registeredClaims)(c)); err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for _, name := range registeredNames {
+		delete(m, name)
+	}
+	c.Extra = nil
+	if len(m) > 0 {
+		c.Extra = m
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+module MiaGame/Library/MiaToken
+
+go 1.17
+
+require (
+	MiaGame/Library/DB v0.0.0
+	MiaGame/Library/MiaCrypt v0.0.0
+	github.com/mediocregopher/radix/v3 v3.6.0
+)
+
+require (
+	MiaGame/Library/MiaError v0.0.0 // indirect
+	MiaGame/Library/MiaLog v0.0.0 // indirect
+	github.com/go-sql-driver/mysql v1.5.0 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.2 // indirect
+	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible // indirect
+	github.com/lestrrat-go/strftime v1.0.5 // indirect
+	github.com/og/x v0.0.0-20201210141255-dbe8c95570d3 // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+	go.uber.org/zap v1.19.1 // indirect
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gorm.io/driver/mysql v1.0.3 // indirect
+	gorm.io/gorm v1.22.2 // indirect
+)
+
+replace (
+	MiaGame/Library/DB => ../DB
+	MiaGame/Library/MiaCrypt => ../MiaCrypt
+	MiaGame/Library/MiaError => ../MiaError
+	MiaGame/Library/MiaLog => ../MiaLog
+)
@@ -0,0 +1,92 @@
+package MiaToken
+
+import (
+	"sync"
+	"time"
+
+	"MiaGame/Library/DB"
+	"github.com/mediocregopher/radix/v3"
+)
+
+// RevocationStore remembers the revoked token ids until the tokens expire.
+type RevocationStore interface {
+	// Revoke revokes id until until, forever when it is zero.
+	Revoke(id string, until time.Time) error
+	IsRevoked(id string) (bool, error)
+}
+
+// MemoryStore is a RevocationStore for a single process.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{revoked: make(map[string]time.Time)}
+}
+
+// Revoke .
+func (s *MemoryStore) Revoke(id string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	// drop the expired ids now and then
+	if len(s.revoked)%64 == 0 {
+		for k, t := range s.revoked {
+			if !t.IsZero() && !now.Before(t) {
+				delete(s.revoked, k)
+			}
+		}
+	}
+	s.revoked[id] = until
+	return nil
+}
+
+// IsRevoked .
+func (s *MemoryStore) IsRevoked(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	until, ok := s.revoked[id]
+	if ok && !until.IsZero() && !time.Now().Before(until) {
+		delete(s.revoked, id)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// RedisStore is a RevocationStore shared by the servers, a key per revoked
+// token expiring with it.
+type RedisStore struct {
+	R *DB.RadixDriver
+	// KeyPrefix defaults to "token:revoked:", the driver prefix is added too.
+	KeyPrefix string
+}
+
+func (s *RedisStore) key(id string) string {
+	if s.KeyPrefix == "" {
+		return "token:revoked:" + id
+	}
+	return s.KeyPrefix + id
+}
+
+// Revoke .
+func (s *RedisStore) Revoke(id string, until time.Time) error {
+	var seconds int64
+	if !until.IsZero() {
+		seconds = int64(time.Until(until)/time.Second) + 1
+		if seconds <= 0 {
+			return nil
+		}
+	}
+	return s.R.Set(s.key(id), 1, seconds)
+}
+
+// IsRevoked .
+func (s *RedisStore) IsRevoked(id string) (bool, error) {
+	var n int
+	if err := s.R.Exec(radix.Cmd(&n, "EXISTS", s.R.Config.Prefix+s.key(id))); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,291 @@
+// Package MiaToken issues and validates the tokens of the game clients: JWTs
+// signed with HS256, RS256 or EdDSA, and local tokens whose claims are
+// encrypted with a MiaCrypt key ring.
+package MiaToken
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"MiaGame/Library/MiaCrypt"
+)
+
+// Algorithms of the tokens, Local is the encrypted token "v1.local.<payload>".
+const (
+	HS256 = "HS256"
+	RS256 = MiaCrypt.RS256
+	EdDSA = MiaCrypt.EdDSA
+	Local = "local"
+)
+
+const localPrefix = "v1.local."
+
+var b64 = base64.RawURLEncoding
+
+// Issuer issues tokens. HS256 and Local tokens use the primary key of Ring,
+// better a ring of their own each, RS256 and EdDSA tokens use Key named KeyID.
+type Issuer struct {
+	Algorithm string
+	Ring      *MiaCrypt.KeyRing
+	KeyID     string
+	Key       crypto.Signer
+
+	// Issuer and Audience fill iss and aud when the claims don't set them.
+	Issuer   string
+	Audience Audience
+	// TTL sets exp when the claims don't, defaults to 2 hours.
+	TTL time.Duration
+}
+
+// Issue fills iat, exp, jti and the defaults of the issuer in c and returns the token.
+func (i *Issuer) Issue(c Claims) (string, error) {
+	now := time.Now()
+	if c.IssuedAt == 0 {
+		c.IssuedAt = now.Unix()
+	}
+	if c.ExpiresAt == 0 {
+		ttl := i.TTL
+		if ttl <= 0 {
+			ttl = 2 * time.Hour
+		}
+		c.ExpiresAt = now.Add(ttl).Unix()
+	}
+	if c.Issuer == "" {
+		c.Issuer = i.Issuer
+	}
+	if len(c.Audience) == 0 {
+		c.Audience = i.Audience
+	}
+	if c.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		c.ID = b64.EncodeToString(id)
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	switch i.Algorithm {
+	case Local:
+		if i.Ring == nil {
+			return "", fmt.Errorf("MiaToken: local tokens need a key ring")
+		}
+		b, err := i.Ring.Encrypt(payload)
+		if err != nil {
+			return "", err
+		}
+		return localPrefix + b64.EncodeToString(b), nil
+	case HS256:
+		if i.Ring == nil {
+			return "", fmt.Errorf("MiaToken: HS256 needs a key ring")
+		}
+		key, err := i.Ring.Primary()
+		if err != nil {
+			return "", err
+		}
+		input := signingInput(i.Algorithm, key.ID, payload)
+		// KeyRing.Verify checks the same HMAC-SHA256
+		sum, err := MiaCrypt.HMAC(MiaCrypt.SHA256, key.Secret, []byte(input))
+		if err != nil {
+			return "", err
+		}
+		return input + "." + b64.EncodeToString(sum), nil
+	case RS256, EdDSA:
+		if i.Key == nil {
+			return "", fmt.Errorf("MiaToken: %s needs a private key", i.Algorithm)
+		}
+		input := signingInput(i.Algorithm, i.KeyID, payload)
+		sig, err := MiaCrypt.Sign(i.Algorithm, i.Key, []byte(input))
+		if err != nil {
+			return "", err
+		}
+		return input + "." + b64.EncodeToString(sig), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrAlgorithm, i.Algorithm)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+func signingInput(alg, kid string, payload []byte) string {
+	h, _ := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	return b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+}
+
+// Validator parses and validates tokens.
+type Validator struct {
+	// Ring verifies HS256 tokens and decrypts Local ones by the kid they carry.
+	Ring *MiaCrypt.KeyRing
+	// PublicKeys verifies RS256 and EdDSA tokens by kid.
+	PublicKeys map[string]crypto.PublicKey
+	// Algorithms accepted, all of them when empty.
+	Algorithms []string
+
+	// Issuer and Audience are checked when not empty.
+	Issuer   string
+	Audience string
+	// Leeway tolerates the clock skew between servers on exp, nbf and iat.
+	Leeway time.Duration
+	// RequireExp rejects tokens without exp.
+	RequireExp bool
+	// Revocations rejects the revoked jti, tokens without jti are rejected when it is set.
+	Revocations RevocationStore
+}
+
+func (v *Validator) allowed(alg string) bool {
+	if len(v.Algorithms) == 0 {
+		return alg == HS256 || alg == RS256 || alg == EdDSA || alg == Local
+	}
+	for _, a := range v.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse verifies or decrypts token and validates its claims.
+func (v *Validator) Parse(token string) (*Claims, error) {
+	var payload []byte
+	var err error
+	if strings.HasPrefix(token, localPrefix) {
+		payload, err = v.openLocal(token[len(localPrefix):])
+	} else {
+		payload, err = v.verifyJWT(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Claims{}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if err := v.Validate(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (v *Validator) openLocal(payload string) ([]byte, error) {
+	if !v.allowed(Local) {
+		return nil, ErrAlgorithm
+	}
+	if v.Ring == nil {
+		return nil, fmt.Errorf("MiaToken: local tokens need a key ring")
+	}
+	b, err := b64.DecodeString(payload)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	plain, err := v.Ring.Decrypt(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	return plain, nil
+}
+
+func (v *Validator) verifyJWT(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	hb, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !v.allowed(h.Alg) || h.Alg == Local {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, h.Alg)
+	}
+	input := []byte(parts[0] + "." + parts[1])
+	switch h.Alg {
+	case HS256:
+		if v.Ring == nil || !v.Ring.Verify(h.Kid, input, sig) {
+			return nil, ErrSignature
+		}
+	case RS256, EdDSA:
+		pub, ok := v.PublicKeys[h.Kid]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v %q", ErrSignature, MiaCrypt.ErrUnknownKey, h.Kid)
+		}
+		if err := MiaCrypt.Verify(h.Alg, pub, input, sig); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignature, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, h.Alg)
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return payload, nil
+}
+
+// Validate checks the time, issuer and audience claims of c and its revocation.
+func (v *Validator) Validate(c *Claims) error {
+	now := time.Now()
+	if c.ExpiresAt == 0 && v.RequireExp {
+		return fmt.Errorf("%w: exp", ErrMissingClaim)
+	}
+	if c.ExpiresAt != 0 && !now.Before(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrNotYetValid
+	}
+	if c.IssuedAt != 0 && now.Add(v.Leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return ErrNotYetValid
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" && !c.Audience.Contains(v.Audience) {
+		return ErrAudience
+	}
+	if v.Revocations != nil {
+		if c.ID == "" {
+			return fmt.Errorf("%w: jti", ErrMissingClaim)
+		}
+		revoked, err := v.Revocations.IsRevoked(c.ID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrRevoked
+		}
+	}
+	return nil
+}
+
+// Revoke revokes the token of c until it expires.
+func (v *Validator) Revoke(c *Claims) error {
+	if v.Revocations == nil {
+		return fmt.Errorf("MiaToken: no revocation store")
+	}
+	if c.ID == "" {
+		return fmt.Errorf("%w: jti", ErrMissingClaim)
+	}
+	var until time.Time
+	if c.ExpiresAt != 0 {
+		until = time.Unix(c.ExpiresAt, 0).Add(v.Leeway)
+	}
+	return v.Revocations.Revoke(c.ID, until)
+}
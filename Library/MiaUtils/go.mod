@@ -3,12 +3,31 @@ module MiaGame/Library/Utils
 go 1.17
 
 require (
+	MiaGame/Library/MiaCrypt v0.0.0
+	MiaGame/Library/MiaLog v0.0.0
 	github.com/google/cel-go v0.9.0
+	github.com/json-iterator/go v1.1.12
+	github.com/modern-go/reflect2 v1.0.2
 	github.com/pkg/errors v0.9.1
 	github.com/shengdoushi/base58 v1.0.0
 )
 
 require (
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible // indirect
+	github.com/lestrrat-go/strftime v1.0.5 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+	go.uber.org/zap v1.19.1 // indirect
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
 	google.golang.org/genproto v0.0.0-20210831024726-fe130286e0e2 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
+
+replace (
+	MiaGame/Library/MiaCrypt => ../MiaCrypt
+	MiaGame/Library/MiaError => ../MiaError
+	MiaGame/Library/MiaLog => ../MiaLog
+)
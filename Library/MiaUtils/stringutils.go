@@ -8,11 +8,11 @@ import (
 	"strings"
 	"time"
 	"encoding/json"
-	"crypto/md5"
 	"encoding/base64"
 	"net/url"
 	"unsafe"
-	"encoding/hex"
+
+	"MiaGame/Library/MiaCrypt"
 )
 const (
 	intType                  = "int"
@@ -546,11 +546,15 @@ func String2Int(s string)(st int) {
 	st,_=strconv.Atoi(s)
 	return
 }
+// StringMd5 返回str的md5十六进制摘要，仅用于兼容，新代码请用StringSum
 func StringMd5(str string) string {
-	hash := md5.New()
-	hash.Write([]byte(str))
+	sum, _ := StringSum(str, MiaCrypt.MD5)
+	return sum
+}
 
-	return hex.EncodeToString(hash.Sum(nil))
+// StringSum 返回str在alg算法下的十六进制摘要，如 MiaCrypt.SHA256
+func StringSum(str string, alg MiaCrypt.HashAlgorithm) (string, error) {
+	return MiaCrypt.SumString(alg, str)
 }
 // String2Base64 返回指定str的base64编码
 func String2Base64(str string) string {
@@ -1,8 +1,8 @@
 package MiaUtils
 
 import (
+	"MiaGame/Library/MiaLog"
 	"context"
-	"fmt"
 	"time"
 )
 
@@ -15,18 +15,13 @@ import (
 type cbfun  func(Grpccontext context.Context)
 type cbFunLogOut func(string)
 func TaskCronInterFace(Grpccontext context.Context ,timeOutmillisecond int64 ,taskfun cbfun ,outPutLog cbFunLogOut) {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fmt.Println("err=", err)
-		}
-	}()
+	defer MiaLog.Recover()
 	if taskfun == nil {
 		return
 	}
 	var timed = time.Duration(timeOutmillisecond) * time.Millisecond
 	tiker := time.NewTicker(timed)
-	go func() {
+	MiaLog.SafeGo(func() {
 		for {
 			select {
 			case <-Grpccontext.Done():
@@ -37,7 +32,11 @@ func TaskCronInterFace(Grpccontext context.Context ,timeOutmillisecond int64 ,ta
 					outPutLog("current Running "+GetFunctionName(taskfun))
 				}
 				if taskfun!=nil  {
-					taskfun(Grpccontext)
+					// a panicking run is logged, the next ticks still run
+					func() {
+						defer MiaLog.Recover()
+						taskfun(Grpccontext)
+					}()
 				}
 				if outPutLog !=nil  {
 					outPutLog("current Running "+GetFunctionName(taskfun))
@@ -45,5 +44,5 @@ func TaskCronInterFace(Grpccontext context.Context ,timeOutmillisecond int64 ,ta
 				
 			}
 		}
-	}()
+	})
 }
\ No newline at end of file
@@ -1,5 +1,6 @@
 package MiaUtils
 import (
+	"MiaGame/Library/MiaLog"
 	"reflect"
 	"fmt"
 	"encoding/json"
@@ -96,16 +97,7 @@ func Map2Struct(vals map[string]interface{}, dst interface{}) (err error) {
 }
 
 func Map2StructTag(vals map[string]interface{}, dst interface{}, tagName string) (err error) {
-	defer func() {
-		e := recover()
-		if e != nil {
-			if v, ok := e.(error); ok {
-				err = fmt.Errorf("Panic: %v", v.Error())
-			} else {
-				err = fmt.Errorf("Panic: %v", e)
-			}
-		}
-	}()
+	defer MiaLog.RecoverError(&err)
 
 	pt := reflect.TypeOf(dst)
 	pv := reflect.ValueOf(dst)
@@ -1,6 +1,7 @@
 package WxService
 
 import (
+	"MiaGame/Library/MiaLog"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
@@ -12,6 +13,9 @@ import (
 	"strconv"
 )
 
+// wxLog is the MiaLog module of the wechat api, secrets in the replies are redacted by MiaLog.
+var wxLog = MiaLog.Named("wx")
+
 // 客户端
 type Client interface {
 	Err() error
@@ -104,7 +108,7 @@ func (c *WxClient) Code2Session(code string) (*UserSession, error) {
 	if c.err != nil {
 		return nil,c.err
 	}
-	fmt.Println(string(body))
+	wxLog.Debug(string(body))
 
 	reply := &UserSession{}
 	err := json.Unmarshal(body, reply)
@@ -165,6 +169,13 @@ func (c *WxClient) UnifiedOrder(p *Params) (prepayId string) {
 		url        string
 		resData    = make(map[string]interface{})
 	)
+	// err is the error of this call for the audit, c.err is only set on failure like in the other calls
+	defer func() {
+		if err != nil {
+			c.err = err
+		}
+		c.audit("wx.unified_order", p, map[string]interface{}{"prepay_id": prepayId}, err)
+	}()
 
 	// 签名
 	if c.IsSandBox {
@@ -177,6 +188,7 @@ func (c *WxClient) UnifiedOrder(p *Params) (prepayId string) {
 
 		c.signParamMD5(p, signKey)
 		if c.err != nil {
+			err = c.err
 			return
 		}
 
@@ -188,63 +200,59 @@ func (c *WxClient) UnifiedOrder(p *Params) (prepayId string) {
 
 	buf, err = xml.Marshal(Xml(p.value))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	res, err = http.Post(url, "application/xml", bytes.NewReader(buf))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	resBody, err = ioutil.ReadAll(res.Body)
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	err = xml.Unmarshal(resBody, (*Xml)(&resData))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	if resData["return_code"] == nil || resData["return_msg"] == nil {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
+		err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
 		return
 	}
 
 	returnCode, ok = resData["return_code"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
 		return
 	}
 
 	if returnCode != "SUCCESS" {
-		c.err = fmt.Errorf(ErrMsgWxRemote, resData["return_msg"])
+		err = fmt.Errorf(ErrMsgWxRemote, resData["return_msg"])
 		return
 	}
 
 	resultCode, ok = resData["result_code"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "result_code类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "result_code类型错误！")
 		return
 	}
 
 	if resultCode != "SUCCESS" {
-		c.err = fmt.Errorf(ErrMsgWxRemote, resData["err_code_des"].(string))
+		err = fmt.Errorf(ErrMsgWxRemote, resData["err_code_des"].(string))
 		return
 	}
 
 	if resData["prepay_id"] == nil {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "响应中没有prepay_id！")
+		err = fmt.Errorf(ErrMsgWxRemote, "响应中没有prepay_id！")
 		return
 	}
 
 	prepayId, ok = resData["prepay_id"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "prepay_id类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "prepay_id类型错误！")
 		return
 	}
 	return resData["prepay_id"].(string)
@@ -326,6 +334,13 @@ func (c *WxClient) Refund(p *Params, keyPath, certPath string) {
 		url        string
 		resData    = make(map[string]interface{})
 	)
+	// err is the error of this call for the audit, c.err is only set on failure like in the other calls
+	defer func() {
+		if err != nil {
+			c.err = err
+		}
+		c.audit("wx.refund", p, map[string]interface{}{"refund_id": resData["refund_id"]}, err)
+	}()
 
 	// 签名
 	if c.IsSandBox {
@@ -346,13 +361,11 @@ func (c *WxClient) Refund(p *Params, keyPath, certPath string) {
 
 	buf, err = xml.Marshal(Xml(p.value))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	cert, err = tls.LoadX509KeyPair(certPath, keyPath)
 	if err != nil {
-		c.err = err
 		return
 	}
 
@@ -366,62 +379,59 @@ func (c *WxClient) Refund(p *Params, keyPath, certPath string) {
 
 	res, err = cl.Post(url, "application/xml", bytes.NewReader(buf))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	resBody, err = ioutil.ReadAll(res.Body)
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	err = xml.Unmarshal(resBody, (*Xml)(&resData))
 	if err != nil {
-		c.err = err
 		return
 	}
 
 	if resData["return_code"] == nil || resData["return_msg"] == nil {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
+		err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
 		return
 	}
 
 	returnCode, ok = resData["return_code"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
 		return
 	}
 
 	if resData["return_code"] == nil || resData["return_msg"] == nil {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
+		err = fmt.Errorf(ErrMsgWxRemote, "响应中没有return_code或return_msg！")
 		return
 	}
 
 	returnCode, ok = resData["return_code"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "return_code类型错误！")
 		return
 	}
 
 	if returnCode != "SUCCESS" {
-		c.err = fmt.Errorf(ErrMsgWxRemote, resData["return_msg"])
+		err = fmt.Errorf(ErrMsgWxRemote, resData["return_msg"])
 		return
 	}
 
 	if resData["result_code"] == nil {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "响应中没有result_code！")
+		err = fmt.Errorf(ErrMsgWxRemote, "响应中没有result_code！")
 		return
 	}
 
 	resultCode, ok = resData["result_code"].(string)
 	if !ok {
-		c.err = fmt.Errorf(ErrMsgWxRemote, "result_code类型错误！")
+		err = fmt.Errorf(ErrMsgWxRemote, "result_code类型错误！")
 		return
 	}
 
 	if resultCode != "SUCCESS" {
-		c.err = fmt.Errorf(ErrMsgWxRemote, resData["err_code_des"].(string))
+		err = fmt.Errorf(ErrMsgWxRemote, resData["err_code_des"].(string))
 		return
 	}
 }
@@ -1085,6 +1095,31 @@ func (c *WxClient) GetDailyVisitPage(accessToken, date string) (result map[strin
 	return
 }
 
+// auditFields are the Params recorded in the audit trail, the signature and secrets are left out,
+// and so are the openid and body of the player since the trail is append-only and kept forever:
+// out_trade_no links a record to the order in the database.
+var auditFields = []string{"appid", "mch_id", "out_trade_no", "transaction_id", "out_refund_no", "total_fee", "refund_fee"}
+
+// audit records a payment operation and the error of the call in the MiaLog audit trail,
+// it does nothing until MiaLog.InitAudit.
+func (c *WxClient) audit(action string, p *Params, result map[string]interface{}, err error) {
+	data := make(map[string]interface{}, len(auditFields)+len(result)+1)
+	for _, k := range auditFields {
+		if v, ok := p.value[k]; ok {
+			data[k] = v
+		}
+	}
+	for k, v := range result {
+		data[k] = v
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	if err := MiaLog.Audit(action, c.MchID, data); err != nil && err != MiaLog.ErrAuditNotInitialized {
+		wxLog.Error("audit ", action, " error:", err)
+	}
+}
+
 func (c *WxClient) signParamMD5(p *Params, key string) {
 	p.value["sign"] = GeneSign(p.value, key)
 }
@@ -1,3 +1,17 @@
 module github.com/chenyingdi/wx-service
 
 go 1.14
+
+require (
+	MiaGame/Library/MiaCrypt v0.0.0
+	MiaGame/Library/MiaLog v0.0.0
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible // indirect
+	github.com/lestrrat-go/strftime v1.0.5 // indirect
+	go.uber.org/zap v1.19.1 // indirect
+)
+
+replace (
+	MiaGame/Library/MiaCrypt => ../MiaCrypt
+	MiaGame/Library/MiaLog => ../MiaLog
+)
@@ -2,27 +2,26 @@ package WxService
 
 import (
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
-	"math/rand"
 	"net"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"MiaGame/Library/MiaCrypt"
 )
 
-// 生成随机字符串
+// 生成随机字符串，字母和数字，来自 crypto/rand
 func GeneNonceStr(len int) string {
-	r := rand.New(rand.NewSource(time.Now().Unix()))
-
-	bytes := make([]byte, len)
-	for i := 0; i < len; i++ {
-		b := r.Intn(26) + 65
-		bytes[i] = byte(b)
+	nonce, err := MiaCrypt.RandomString(len, MiaCrypt.AlphabetAlphanumeric)
+	if err != nil {
+		// crypto/rand 读取失败时无法生成安全的随机数
+		panic(err)
 	}
-
-	return string(bytes)
+	return nonce
 }
 
 // 签名
@@ -66,7 +65,7 @@ func CheckSign(key string, params map[string]interface{}) (bool, error) {
 
 	newSign = GeneSign(params, key)
 
-	return newSign == sign, nil
+	return subtle.ConstantTimeCompare([]byte(newSign), []byte(sign)) == 1, nil
 }
 
 func ParseMap(args map[string]interface{}) string {
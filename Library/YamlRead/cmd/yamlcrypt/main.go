@@ -0,0 +1,261 @@
+// Command yamlcrypt encrypts, decrypts and rotates the ENC(...) values of a yaml
+// config in place.
+//
+//	yamlcrypt [-key k | -keyfile f] encrypt config.yaml mysql.password redis.password
+//	yamlcrypt [-key k | -keyfile f] decrypt config.yaml [path...]
+//	yamlcrypt [-key k | -keyfile f] -newkey k2 rotate config.yaml
+//	yamlcrypt [-key k | -keyfile f] value <plaintext>
+//	yamlcrypt -keyring ring.json encrypt|decrypt|rotate config.yaml [path...]
+//
+// The key defaults to $MIA_CONFIG_KEY or the file named by $MIA_CONFIG_KEY_FILE.
+// With a MiaCrypt key ring, from -keyring or $MIA_CONFIG_KEYRING, values are
+// encrypted with its primary key and rotate re-encrypts those of older keys.
+// Paths are the keys joined with ".", sequence items by their index.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"MiaGame/Library/MiaCrypt"
+	"MiaGame/Library/YamlRead"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	key := flag.String("key", "", "key or passphrase, defaults to $"+YamlRead.KeyEnv)
+	keyFile := flag.String("keyfile", "", "file holding the key, defaults to $"+YamlRead.KeyFileEnv)
+	newKey := flag.String("newkey", "", "key of rotate")
+	keyRing := flag.String("keyring", "", "key ring file, defaults to $"+YamlRead.KeyRingEnv)
+	raw := flag.Bool("raw", false, "use the key as is, 16, 24 or 32 bytes, instead of deriving it with Argon2id")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: yamlcrypt [flags] encrypt|decrypt|rotate file [path...]\n       yamlcrypt [flags] value plaintext")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, file, paths := flag.Arg(0), flag.Arg(1), flag.Args()[2:]
+	ring, err := loadKeyRing(*keyRing, *key != "" || *keyFile != "")
+	if err != nil {
+		fail(err)
+	}
+	if ring != nil {
+		if cmd == "value" {
+			printValue(YamlRead.EncryptValueRing(file, ring))
+			return
+		}
+		run(file, paths, cmd, ringFunc(cmd, ring))
+		return
+	}
+	k, err := loadKey(*key, *keyFile)
+	if err != nil {
+		fail(err)
+	}
+	if k == "" {
+		fail(YamlRead.ErrNoSecretKey)
+	}
+
+	if cmd == "value" {
+		printValue(YamlRead.EncryptValue(file, k, !*raw))
+		return
+	}
+
+	var fn func(path string, n *yaml.Node) error
+	switch cmd {
+	case "encrypt":
+		fn = func(path string, n *yaml.Node) error {
+			if YamlRead.IsEncrypted(n.Value) {
+				return nil
+			}
+			v, err := YamlRead.EncryptValue(n.Value, k, !*raw)
+			n.Value = v
+			return err
+		}
+	case "decrypt":
+		fn = func(path string, n *yaml.Node) error {
+			v, err := YamlRead.DecryptValue(n.Value, k)
+			n.Value = v
+			return err
+		}
+	case "rotate":
+		if *newKey == "" {
+			fail(fmt.Errorf("rotate needs -newkey"))
+		}
+		fn = func(path string, n *yaml.Node) error {
+			if !YamlRead.IsEncrypted(n.Value) {
+				return nil
+			}
+			v, err := YamlRead.DecryptValue(n.Value, k)
+			if err != nil {
+				return err
+			}
+			n.Value, err = YamlRead.EncryptValue(v, *newKey, !*raw)
+			return err
+		}
+	}
+	run(file, paths, cmd, fn)
+}
+
+// ringFunc is the function of cmd with a key ring.
+func ringFunc(cmd string, ring *MiaCrypt.KeyRing) func(path string, n *yaml.Node) error {
+	switch cmd {
+	case "encrypt":
+		return func(path string, n *yaml.Node) error {
+			if YamlRead.IsEncrypted(n.Value) {
+				return nil
+			}
+			v, err := YamlRead.EncryptValueRing(n.Value, ring)
+			n.Value = v
+			return err
+		}
+	case "decrypt":
+		return func(path string, n *yaml.Node) error {
+			v, err := YamlRead.DecryptValueRing(n.Value, ring)
+			n.Value = v
+			return err
+		}
+	case "rotate":
+		return func(path string, n *yaml.Node) error {
+			if !YamlRead.IsEncrypted(n.Value) || !ring.NeedsRotation(strings.TrimSuffix(strings.TrimPrefix(n.Value, "ENC("), ")")) {
+				return nil
+			}
+			v, err := YamlRead.DecryptValueRing(n.Value, ring)
+			if err != nil {
+				return err
+			}
+			n.Value, err = YamlRead.EncryptValueRing(v, ring)
+			return err
+		}
+	}
+	return nil
+}
+
+func printValue(v string, err error) {
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(v)
+}
+
+func run(file string, paths []string, cmd string, fn func(path string, n *yaml.Node) error) {
+	if fn == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if cmd == "encrypt" && len(paths) == 0 {
+		fail(fmt.Errorf("encrypt needs the paths of the values"))
+	}
+	count, err := rewrite(file, paths, fn)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("%s: %d values changed\n", file, count)
+}
+
+// loadKeyRing returns the ring of -keyring or of the env, unless a key was given.
+func loadKeyRing(file string, hasKey bool) (*MiaCrypt.KeyRing, error) {
+	if file != "" {
+		return MiaCrypt.LoadKeyRingFile(file)
+	}
+	if hasKey {
+		return nil, nil
+	}
+	return YamlRead.SecretKeyRing()
+}
+
+func loadKey(key, keyFile string) (string, error) {
+	if key != "" {
+		return key, nil
+	}
+	if keyFile != "" {
+		return YamlRead.ReadKeyFile(keyFile)
+	}
+	return YamlRead.SecretKey()
+}
+
+// rewrite calls fn on the scalar values of file, or only those of paths, and
+// replaces the file when they are all done.
+func rewrite(file string, paths []string, fn func(path string, n *yaml.Node) error) (int, error) {
+	in, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return 0, err
+	}
+	want := make(map[string]bool)
+	for _, p := range paths {
+		want[p] = true
+	}
+	count := 0
+	err = YamlRead.WalkScalars(&doc, func(path string, n *yaml.Node) error {
+		if len(paths) > 0 && !want[path] {
+			return nil
+		}
+		delete(want, path)
+		before := n.Value
+		if err := fn(path, n); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if n.Value != before {
+			// a decrypted "123456" must not be read back as a number
+			n.Tag, n.Style = "!!str", yaml.DoubleQuotedStyle
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for p := range want {
+		return 0, fmt.Errorf("%s: no such value", p)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return 0, err
+	}
+	enc.Close()
+	return count, writeFile(file, out.Bytes())
+}
+
+// writeFile replaces file through a temporary file so a failure leaves it intact.
+func writeFile(file string, data []byte) error {
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
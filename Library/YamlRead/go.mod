@@ -3,6 +3,8 @@ module MiaGame/Library/YamlRead
 go 1.14
 
 require (
-	gopkg.in/yaml.v2 v2.3.0
+	MiaGame/Library/MiaCrypt v0.0.0
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 )
+
+replace MiaGame/Library/MiaCrypt => ../MiaCrypt
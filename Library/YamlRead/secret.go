@@ -0,0 +1,231 @@
+package YamlRead
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"MiaGame/Library/MiaCrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置中加密的值写成 ENC(...)，括号内是 MiaCrypt 的密文，加载时自动解密
+const (
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+const (
+	// KeyEnv 是解密配置用的密钥（或口令）的环境变量
+	KeyEnv = "MIA_CONFIG_KEY"
+	// KeyFileEnv 是密钥文件路径的环境变量，KeyEnv 为空时使用
+	KeyFileEnv = "MIA_CONFIG_KEY_FILE"
+	// KeyRingEnv 是 MiaCrypt 密钥环 json 的环境变量，KeyRingEnv + "_FILE" 是它的文件，优先于 KeyEnv
+	KeyRingEnv = "MIA_CONFIG_KEYRING"
+)
+
+// ErrNoSecretKey 配置中有 ENC(...) 但没有密钥
+var ErrNoSecretKey = errors.New("YamlRead: encrypted value without key, set " + KeyEnv + " or " + KeyFileEnv)
+
+var secretKey struct {
+	sync.RWMutex
+	key  string
+	set  bool
+	ring *MiaCrypt.KeyRing
+}
+
+// SetSecretKey 设置解密的密钥，代替环境变量
+func SetSecretKey(key string) {
+	secretKey.Lock()
+	secretKey.key, secretKey.set = key, true
+	secretKey.Unlock()
+}
+
+// SetSecretKeyRing 设置解密的密钥环，优先于密钥
+func SetSecretKeyRing(ring *MiaCrypt.KeyRing) {
+	secretKey.Lock()
+	secretKey.ring = ring
+	secretKey.Unlock()
+}
+
+// SecretKeyRing 返回 SetSecretKeyRing 设置的或 KeyRingEnv 中的密钥环，都没有时返回 nil
+func SecretKeyRing() (*MiaCrypt.KeyRing, error) {
+	secretKey.RLock()
+	ring := secretKey.ring
+	secretKey.RUnlock()
+	if ring != nil {
+		return ring, nil
+	}
+	if os.Getenv(KeyRingEnv) == "" && os.Getenv(KeyRingEnv+"_FILE") == "" {
+		return nil, nil
+	}
+	return MiaCrypt.LoadKeyRingEnv(KeyRingEnv)
+}
+
+// SecretKey 返回解密的密钥：SetSecretKey 设置的、KeyEnv，或 KeyFileEnv 指向的文件内容
+func SecretKey() (string, error) {
+	secretKey.RLock()
+	key, set := secretKey.key, secretKey.set
+	secretKey.RUnlock()
+	if set {
+		return key, nil
+	}
+	if key := os.Getenv(KeyEnv); key != "" {
+		return key, nil
+	}
+	if file := os.Getenv(KeyFileEnv); file != "" {
+		return ReadKeyFile(file)
+	}
+	return "", nil
+}
+
+// ReadKeyFile 读取密钥文件，去掉首尾的空白
+func ReadKeyFile(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// IsEncrypted 判断 value 是否为 ENC(...)
+func IsEncrypted(value string) bool {
+	return len(value) >= len(encPrefix)+len(encSuffix) &&
+		strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, encSuffix)
+}
+
+// EncryptValue 加密 value，返回 ENC(...)。passphrase 为 true 时用 Argon2id 从 key 派生密钥
+func EncryptValue(value, key string, passphrase bool) (string, error) {
+	var text string
+	var err error
+	if passphrase {
+		text, err = MiaCrypt.StringEncryptPassphrase(value, key)
+	} else {
+		text, err = MiaCrypt.StringEncrypt(value, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + text + encSuffix, nil
+}
+
+// EncryptValueRing 用密钥环的当前密钥加密 value，返回 ENC(...)
+func EncryptValueRing(value string, ring *MiaCrypt.KeyRing) (string, error) {
+	text, err := ring.EncryptString(value)
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + text + encSuffix, nil
+}
+
+// DecryptValueRing 用密钥环解密 ENC(...)，其它值原样返回
+func DecryptValueRing(value string, ring *MiaCrypt.KeyRing) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	return ring.DecryptString(value[len(encPrefix) : len(value)-len(encSuffix)])
+}
+
+// DecryptValue 解密 ENC(...)，其它值原样返回
+func DecryptValue(value, key string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if key == "" {
+		return "", ErrNoSecretKey
+	}
+	return MiaCrypt.StringDecrypt(value[len(encPrefix):len(value)-len(encSuffix)], key)
+}
+
+// WalkScalars 对 node 下所有标量的值调用 fn，path 是用 "." 连接的键，序列的下标为数字
+func WalkScalars(node *yaml.Node, fn func(path string, n *yaml.Node) error) error {
+	return walkScalars(node, "", fn)
+}
+
+func walkScalars(node *yaml.Node, path string, fn func(path string, n *yaml.Node) error) error {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := walkScalars(n, path, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := walkScalars(node.Content[i+1], join(node.Content[i].Value), fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			if err := walkScalars(n, join(fmt.Sprint(i)), fn); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return fn(path, node)
+	}
+	return nil
+}
+
+// decryptNode 解密 node 中所有的 ENC(...)，有密钥环时用密钥环
+func decryptNode(node *yaml.Node) error {
+	var decrypt func(value string) (string, error)
+	return WalkScalars(node, func(path string, n *yaml.Node) error {
+		if n.ShortTag() != "!!str" || !IsEncrypted(n.Value) {
+			return nil
+		}
+		if decrypt == nil {
+			var err error
+			if decrypt, err = secretDecrypter(); err != nil {
+				return err
+			}
+		}
+		value, err := decrypt(n.Value)
+		if err != nil {
+			return fmt.Errorf("decrypt %s: %w", path, err)
+		}
+		// 解密后的 "123456" 仍然是字符串
+		n.Value, n.Tag = value, "!!str"
+		return nil
+	})
+}
+
+func secretDecrypter() (func(value string) (string, error), error) {
+	ring, err := SecretKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	if ring != nil {
+		return func(value string) (string, error) { return DecryptValueRing(value, ring) }, nil
+	}
+	key, err := SecretKey()
+	if err != nil {
+		return nil, err
+	}
+	return func(value string) (string, error) { return DecryptValue(value, key) }, nil
+}
+
+// unmarshal 是解密 ENC(...) 的 yaml.Unmarshal
+func unmarshal(in []byte, out interface{}) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(in, &node); err != nil {
+		return err
+	}
+	if node.Kind == 0 {
+		return nil
+	}
+	if err := decryptNode(&node); err != nil {
+		return err
+	}
+	return node.Decode(out)
+}
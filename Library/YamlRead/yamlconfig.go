@@ -4,7 +4,6 @@ package YamlRead
 import (
 	"errors"
 	"fmt"
-	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"path"
 	"reflect"
@@ -17,7 +16,7 @@ type ConfigEngine struct {
 }
 
 
-// 将yaml文件中的内容进行加载
+// 将yaml文件中的内容进行加载，ENC(...) 的值会用 SecretKey 解密
 func (c *ConfigEngine) Load (path string) error {
 	ext := c.guessFileType(path)
 	if !ext {
@@ -43,9 +42,9 @@ func (c *ConfigEngine) loadFromYaml(path string) error {
 		return readErr
 	}
 	// yaml解析的时候c.data如果没有被初始化，会自动为你做初始化
-	err := yaml.Unmarshal(yamlS, &c.data)
+	err := unmarshal(yamlS, &c.data)
 	if err != nil {
-		return errors.New("can not parse "+ path + " config" )
+		return errors.New("can not parse "+ path + " config " + err.Error())
 	}
 	return nil
 }
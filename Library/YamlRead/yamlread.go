@@ -1,13 +1,12 @@
 package YamlRead
 import (
 	"errors"
-	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"path"
 )
 
 
-// 将yaml文件中的内容进行加载
+// 将yaml文件中的内容进行加载，ENC(...) 的值会用 SecretKey 解密
 func Load (path string ,result interface{}) error {
 	ext := guessFileType(path)
 	if !ext  {
@@ -39,7 +38,7 @@ func  loadFromYaml(path string ,result interface{}) error {
 		return readErr
 	}
 	// yaml解析的时候c.data如果没有被初始化，会自动为你做初始化
-	err := yaml.Unmarshal(yamlS, result)
+	err := unmarshal(yamlS, result)
 	if err != nil {
 		return errors.New("can not parse "+ path + " config" +err.Error())
 	}